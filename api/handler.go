@@ -1,6 +1,11 @@
 package api
 
 import (
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
 	"log/slog"
 	"net/http"
 )
@@ -27,15 +32,52 @@ func handleError(w http.ResponseWriter, r *http.Request, err error) {
 	}
 }
 
-func createHandler(handler RouteHandler) http.HandlerFunc {
+// tracer returns the package's tracer, looked up on every use like cache's.
+func tracer() trace.Tracer {
+	return otel.Tracer("github.com/anmho/caching/api")
+}
+
+// statusRecorder remembers the status code written to the response.
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+}
+
+func (w *statusRecorder) WriteHeader(status int) {
+	w.status = status
+	w.ResponseWriter.WriteHeader(status)
+}
+
+// createHandler adapts handler to net/http, writing its errors as JSON. Every
+// request is traced in a span named after pattern, continuing the trace of the
+// caller if it sent one.
+func createHandler(pattern string, handler RouteHandler) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
-		err := handler(w, r)
+		ctx := otel.GetTextMapPropagator().Extract(r.Context(), propagation.HeaderCarrier(r.Header))
+		ctx, span := tracer().Start(ctx, pattern,
+			trace.WithSpanKind(trace.SpanKindServer),
+			trace.WithAttributes(
+				attribute.String("http.request.method", r.Method),
+				attribute.String("http.route", pattern),
+				attribute.String("url.path", r.URL.Path),
+			),
+		)
+		defer span.End()
+
+		rec := &statusRecorder{ResponseWriter: w, status: http.StatusOK}
+		err := handler(rec, r.WithContext(ctx))
 		if err != nil {
-			handleError(w, r, err)
+			span.RecordError(err)
+			handleError(rec, r, err)
+		}
+
+		span.SetAttributes(attribute.Int("http.response.status_code", rec.status))
+		if rec.status >= http.StatusInternalServerError {
+			span.SetStatus(codes.Error, http.StatusText(rec.status))
 		}
 	}
 }
 
 func register(mux *http.ServeMux, pattern string, handler RouteHandler) {
-	mux.HandleFunc(pattern, createHandler(handler))
+	mux.HandleFunc(pattern, createHandler(pattern, handler))
 }
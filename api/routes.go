@@ -61,8 +61,14 @@ func handleGetTodoByID(todoService *todo.Service) RouteHandler {
 		}
 
 		userID, err := uuid.Parse(r.URL.Query().Get("user-id"))
+		if err != nil {
+			return NewError(err, WithStatus(http.StatusBadRequest))
+		}
 
 		todoItem, err := todoService.FindTodoByID(r.Context(), userID, id)
+		if errors.Is(err, todo.TodoNotFoundError) {
+			return NewError(err, WithStatus(http.StatusNotFound))
+		}
 		if err != nil {
 			return err
 		}
@@ -119,6 +125,9 @@ func handleUpdateTodo(todoService *todo.Service) RouteHandler {
 			userID,
 			id,
 			params)
+		if errors.Is(err, todo.TodoNotFoundError) {
+			return NewError(err, WithStatus(http.StatusNotFound))
+		}
 		if err != nil {
 			return err
 		}
@@ -128,5 +137,26 @@ func handleUpdateTodo(todoService *todo.Service) RouteHandler {
 }
 
 func handleDeleteTodo(todoService *todo.Service) RouteHandler {
-	return func(w http.ResponseWriter, r *http.Request) error { return nil }
+	return func(w http.ResponseWriter, r *http.Request) error {
+		id, err := uuid.Parse(r.PathValue("id"))
+		if err != nil {
+			return NewError(err, WithStatus(http.StatusBadRequest))
+		}
+
+		userID, err := uuid.Parse(r.URL.Query().Get("user-id"))
+		if err != nil {
+			return NewError(err, WithStatus(http.StatusBadRequest))
+		}
+
+		err = todoService.DeleteTodo(r.Context(), userID, id)
+		if errors.Is(err, todo.TodoNotFoundError) {
+			return NewError(err, WithStatus(http.StatusNotFound))
+		}
+		if err != nil {
+			return err
+		}
+
+		w.WriteHeader(http.StatusNoContent)
+		return nil
+	}
 }
@@ -0,0 +1,76 @@
+package api
+
+import (
+	"context"
+	"github.com/anmho/caching/todo"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+// deleteDynamo is a TodoItems table that only supports DeleteItem.
+type deleteDynamo struct {
+	todo.DynamoDBClient
+	items map[string]bool
+}
+
+func (d *deleteDynamo) DeleteItem(ctx context.Context, params *dynamodb.DeleteItemInput, optFns ...func(*dynamodb.Options)) (*dynamodb.DeleteItemOutput, error) {
+	key := params.Key["UserID"].(*types.AttributeValueMemberS).Value + "/" + params.Key["ID"].(*types.AttributeValueMemberS).Value
+	if !d.items[key] {
+		return nil, &types.ConditionalCheckFailedException{Message: aws.String("The conditional request failed")}
+	}
+	delete(d.items, key)
+	return &dynamodb.DeleteItemOutput{}, nil
+}
+
+func TestDeleteTodoRoute(t *testing.T) {
+	userID, id := uuid.New(), uuid.New()
+
+	tests := []struct {
+		desc   string
+		path   string
+		status int
+	}{
+		{
+			desc:   "happy path: the todo is deleted",
+			path:   "/todos/" + id.String() + "?user-id=" + userID.String(),
+			status: http.StatusNoContent,
+		},
+		{
+			desc:   "a missing todo is not found",
+			path:   "/todos/" + uuid.NewString() + "?user-id=" + userID.String(),
+			status: http.StatusNotFound,
+		},
+		{
+			desc:   "a todo of another user is not found",
+			path:   "/todos/" + id.String() + "?user-id=" + uuid.NewString(),
+			status: http.StatusNotFound,
+		},
+		{
+			desc:   "an invalid id is rejected",
+			path:   "/todos/abc?user-id=" + userID.String(),
+			status: http.StatusBadRequest,
+		},
+		{
+			desc:   "a missing user id is rejected",
+			path:   "/todos/" + id.String(),
+			status: http.StatusBadRequest,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.desc, func(t *testing.T) {
+			dynamo := &deleteDynamo{items: map[string]bool{userID.String() + "/" + id.String(): true}}
+			mux := New(todo.MakeService(dynamo, nil))
+
+			rec := httptest.NewRecorder()
+			mux.ServeHTTP(rec, httptest.NewRequest(http.MethodDelete, tc.path, nil))
+			assert.Equal(t, tc.status, rec.Code)
+		})
+	}
+}
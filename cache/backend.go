@@ -0,0 +1,57 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+var MissError = errors.New("key not found in cache backend")
+
+// KeyValue is one of the entries written by MSet.
+type KeyValue struct {
+	Key   string
+	Value []byte
+	TTL   time.Duration
+}
+
+// LeasedKeyValue is one of the entries written by MSetLeased. It is only set
+// while LeaseKey still holds Token.
+type LeasedKeyValue struct {
+	KeyValue
+	LeaseKey string
+	Token    string
+}
+
+// Backend is the storage a Cache keeps encoded entries in. A TTL of zero means
+// the entry does not expire.
+type Backend interface {
+	// Get returns MissError if key is not stored.
+	Get(ctx context.Context, key string) ([]byte, error)
+	// MGet returns one value per key, with nil for keys that are not stored.
+	MGet(ctx context.Context, keys ...string) ([][]byte, error)
+	Set(ctx context.Context, key string, value []byte, ttl time.Duration) error
+	// MSet sets every item, each with its own TTL, in as few round trips as
+	// the backend allows.
+	MSet(ctx context.Context, items ...KeyValue) error
+	Delete(ctx context.Context, keys ...string) error
+	// Expire sets the TTL of key if it is stored.
+	Expire(ctx context.Context, key string, ttl time.Duration) error
+	// Incr increments the integer stored at key, starting from zero, and
+	// returns the new value.
+	Incr(ctx context.Context, key string) (int64, error)
+
+	// Lease stores token at leaseKey for ttl, unless another lease is already
+	// stored there, and reports whether it did.
+	Lease(ctx context.Context, leaseKey string, token string, ttl time.Duration) (bool, error)
+	// SetLeased sets key like Set, but only while leaseKey still holds token.
+	// It releases the lease and reports whether the value was set.
+	SetLeased(ctx context.Context, key string, leaseKey string, token string, value []byte, ttl time.Duration) (bool, error)
+	// MLease is Lease for several leases in as few round trips as the backend
+	// allows. Each item holds a lease key, its token and its TTL. It reports
+	// per item whether the lease was granted.
+	MLease(ctx context.Context, leases ...KeyValue) ([]bool, error)
+	// MSetLeased is SetLeased for several items in as few round trips as the
+	// backend allows. It reports per item whether the value was set.
+	MSetLeased(ctx context.Context, items ...LeasedKeyValue) ([]bool, error)
+}
@@ -2,29 +2,81 @@ package cache
 
 import (
 	"context"
-	"encoding/json"
 	"errors"
-	"github.com/go-redis/redis/v8"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+	"log/slog"
+	"maps"
+	"strconv"
 	"time"
 )
 
+var (
+	NoLoaderError = errors.New("cache was not built with a loader")
+	// NotFoundError is returned, or wrapped, by a Loader when the source of
+	// truth does not have the key. The miss is then cached as a tombstone.
+	NotFoundError = errors.New("not found in source of truth")
+)
+
+// Loader loads the value for key from the source of truth when it is not in
+// the cache.
+type Loader[T any] func(ctx context.Context, key string) (*T, error)
+
 type Cache[T any] struct {
-	redisClient *redis.Client
+	backend Backend
+	loader  Loader[T]
+	opts    cacheOptions
+	flights flightGroup[T]
+	stats   cacheStats
+	now     func() time.Time
 }
 
 func New[T any](
-	redisClient *redis.Client,
+	backend Backend,
+	opts ...CacheOpt,
 ) *Cache[T] {
-	return &Cache[T]{
-		redisClient: redisClient,
+	c := &Cache[T]{
+		opts: newCacheOptions(opts),
+		now:  time.Now,
 	}
+	c.backend = c.opts.wrap(backend)
+	return c
 }
 
-func (c *Cache[T]) InvalidateKey(ctx context.Context, key string) error {
+// NewReadThrough builds a cache whose Get loads misses with loader.
+func NewReadThrough[T any](
+	backend Backend,
+	loader Loader[T],
+	opts ...CacheOpt,
+) *Cache[T] {
+	c := &Cache[T]{
+		loader: loader,
+		opts:   newCacheOptions(opts),
+		now:    time.Now,
+	}
+	c.backend = c.opts.wrap(backend)
+	return c
+}
 
-	cmd := c.redisClient.Del(ctx, key)
+// key returns the backend key holding key, see WithNamespace.
+func (c *Cache[T]) key(key string) string {
+	if c.opts.namespace == "" {
+		return key
+	}
+	return c.opts.namespace + ":v" + strconv.Itoa(c.opts.schemaVersion) + ":" + key
+}
+
+// InvalidateKey removes key, and cancels any lease handed out for it.
+func (c *Cache[T]) InvalidateKey(ctx context.Context, key string) (err error) {
+	ctx, end := c.begin(ctx, opInvalidate, attribute.String("cache.key", key))
+	defer end(&err)
+
+	keys := []string{c.key(key)}
+	if c.opts.leaseTTL > 0 {
+		keys = append(keys, leaseKey(c.key(key)))
+	}
 
-	err := cmd.Err()
+	err = c.backend.Delete(ctx, keys...)
 	if err != nil {
 		return err
 	}
@@ -32,16 +84,41 @@ func (c *Cache[T]) InvalidateKey(ctx context.Context, key string) error {
 	return nil
 }
 
-func (c *Cache[T]) WriteItem(ctx context.Context, key string, data *T) error {
+// InvalidateKeys removes every key in one call to the backend, and cancels any
+// leases handed out for them.
+func (c *Cache[T]) InvalidateKeys(ctx context.Context, keys ...string) (err error) {
+	if len(keys) == 0 {
+		return nil
+	}
+	ctx, end := c.begin(ctx, opInvalidateMany, attribute.Int("cache.keys", len(keys)))
+	defer end(&err)
+
+	backendKeys := make([]string, 0, 2*len(keys))
+	for _, key := range keys {
+		backendKeys = append(backendKeys, c.key(key))
+		if c.opts.leaseTTL > 0 {
+			backendKeys = append(backendKeys, leaseKey(c.key(key)))
+		}
+	}
+
+	return c.backend.Delete(ctx, backendKeys...)
+}
+
+func (c *Cache[T]) WriteItem(ctx context.Context, key string, data *T, opts ...WriteOpt) (err error) {
+	ctx, end := c.begin(ctx, opWrite, attribute.String("cache.key", key))
+	defer end(&err)
 
-	b, err := json.Marshal(data)
+	o := c.writeOptions(opts)
+	versions, err := c.leasedTagVersions(ctx, o.tags, o.lease)
+	if err != nil {
+		return err
+	}
+	b, hardTTL, err := c.encode(key, data, &o, versions)
 	if err != nil {
 		return err
 	}
 
-	cmd := c.redisClient.Set(ctx, key, b, time.Minute*0)
-	err = cmd.Err()
-
+	err = c.set(ctx, key, b, hardTTL, o.lease)
 	if err != nil {
 		return err
 	}
@@ -49,39 +126,590 @@ func (c *Cache[T]) WriteItem(ctx context.Context, key string, data *T) error {
 	return nil
 }
 
+// WriteItems writes every item in one call to the backend. Items are written
+// unleased, so the WithLease write option does not apply, and any lease handed
+// out for them is cancelled.
+func (c *Cache[T]) WriteItems(ctx context.Context, items map[string]*T, opts ...WriteOpt) (err error) {
+	if len(items) == 0 {
+		return nil
+	}
+	ctx, end := c.begin(ctx, opWriteMany, attribute.Int("cache.keys", len(items)))
+	defer end(&err)
+
+	o := c.writeOptions(opts)
+	versions, err := c.tagVersions(ctx, o.tags)
+	if err != nil {
+		return err
+	}
+	kvs := make([]KeyValue, 0, len(items))
+	leaseKeys := make([]string, 0, len(items))
+	for key, data := range items {
+		b, hardTTL, err := c.encode(key, data, &o, versions)
+		if err != nil {
+			return err
+		}
+		kvs = append(kvs, KeyValue{Key: c.key(key), Value: b, TTL: hardTTL})
+		leaseKeys = append(leaseKeys, leaseKey(c.key(key)))
+	}
+
+	// Like an unleased set, the batch outranks fills that are in flight.
+	if c.opts.leaseTTL > 0 {
+		err := c.backend.Delete(ctx, leaseKeys...)
+		if err != nil {
+			return err
+		}
+	}
+	return c.backend.MSet(ctx, kvs...)
+}
+
+// FillItems writes every item under its lease in one call to the backend. Items
+// are tagged with the versions their lease recorded, see WithLeaseTags, and
+// any other tag versions are read once for the whole batch. Items whose lease is not
+// granted or was lost are skipped, like a WriteItem failing with
+// LeaseLostError. The WithLease write option does not apply.
+func (c *Cache[T]) FillItems(ctx context.Context, items map[string]*T, leases map[string]*Lease, opts ...WriteOpt) (err error) {
+	if len(items) == 0 {
+		return nil
+	}
+	ctx, end := c.begin(ctx, opFillMany, attribute.Int("cache.keys", len(items)))
+	defer end(&err)
+
+	o := c.writeOptions(opts)
+	// The tags some lease has no version for are read once for the batch.
+	var unknown []string
+	seen := make(map[string]bool)
+	for key := range items {
+		if lease := leases[key]; lease.Granted() {
+			_, tags := lease.tagVersions(o.tags)
+			for _, tag := range tags {
+				if !seen[tag] {
+					seen[tag] = true
+					unknown = append(unknown, tag)
+				}
+			}
+		}
+	}
+	current, err := c.tagVersions(ctx, unknown)
+	if err != nil {
+		return err
+	}
+	kvs := make([]LeasedKeyValue, 0, len(items))
+	for key, data := range items {
+		lease := leases[key]
+		if !lease.Granted() {
+			continue
+		}
+		versions, _ := lease.tagVersions(o.tags)
+		if versions == nil {
+			versions = current
+		} else {
+			maps.Copy(versions, current)
+		}
+		b, hardTTL, err := c.encode(key, data, &o, versions)
+		if err != nil {
+			return err
+		}
+		kvs = append(kvs, LeasedKeyValue{
+			KeyValue: KeyValue{Key: c.key(key), Value: b, TTL: hardTTL},
+			LeaseKey: leaseKey(c.key(key)),
+			Token:    lease.token,
+		})
+	}
+	if len(kvs) == 0 {
+		return nil
+	}
+	_, err = c.backend.MSetLeased(ctx, kvs...)
+	return err
+}
+
+func (c *Cache[T]) writeOptions(opts []WriteOpt) writeOptions {
+	o := writeOptions{ttl: c.opts.defaultTTL}
+	for _, opt := range opts {
+		opt(&o)
+	}
+	return o
+}
+
+// encode wraps data in an entry tagged with the given tag versions and returns
+// it with the TTL the backend should keep it for.
+func (c *Cache[T]) encode(key string, data *T, o *writeOptions, versions map[string]int64) ([]byte, time.Duration, error) {
+	ttl := c.opts.expiration(o.ttl)
+	e := newEntry(data, c.now(), ttl, o.computeCost)
+	e.Tags = versions
+	b, err := c.marshal(c.key(key), e)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	// The backend keeps the entry around for the stale windows after it
+	// expires.
+	hardTTL := ttl
+	if hardTTL > 0 {
+		hardTTL += c.opts.staleWindow()
+	}
+	return b, hardTTL, nil
+}
+
+// WriteMissing stores a tombstone recording that the source of truth does not
+// have key, so that reads of it do not reach the source until the tombstone
+// expires. Tombstones live for the TTL set with WithNegativeTTL, and are not
+// written if it is unset, in which case the lease passed WithLease is
+// released. Only the WithLease and WithTags write options apply.
+func (c *Cache[T]) WriteMissing(ctx context.Context, key string, opts ...WriteOpt) (err error) {
+	var o writeOptions
+	for _, opt := range opts {
+		opt(&o)
+	}
+	if c.opts.negativeTTL <= 0 {
+		return c.ReleaseLease(ctx, key, o.lease)
+	}
+	ctx, end := c.begin(ctx, opWriteMissing, attribute.String("cache.key", key))
+	defer end(&err)
+	versions, err := c.leasedTagVersions(ctx, o.tags, o.lease)
+	if err != nil {
+		return err
+	}
+
+	b, err := c.marshal(c.key(key), &entry[T]{Missing: true, Tags: versions})
+	if err != nil {
+		return err
+	}
+	return c.set(ctx, key, b, c.opts.negativeTTL, o.lease)
+}
+
+// CountMiss records a miss for key and returns how many misses it has had
+// within window, counting from its first miss. The counter is shared by every
+// instance sharing the backend.
+func (c *Cache[T]) CountMiss(ctx context.Context, key string, window time.Duration) (int64, error) {
+	missKey := missCounterKey(c.key(key))
+	misses, err := c.backend.Incr(ctx, missKey)
+	if err != nil {
+		return 0, err
+	}
+	if misses == 1 {
+		err = c.backend.Expire(ctx, missKey, window)
+		if err != nil {
+			return 0, err
+		}
+	}
+	return misses, nil
+}
+
+// ResetMisses clears the miss counter for key.
+func (c *Cache[T]) ResetMisses(ctx context.Context, key string) error {
+	return c.backend.Delete(ctx, missCounterKey(c.key(key)))
+}
+
+func missCounterKey(key string) string {
+	return "misses:" + key
+}
+
+// ReadCacheResult is the outcome of a read.
+//
+// Stale is set when Data is past its TTL but still within a stale window (see
+// WithStaleWhileRevalidate and WithStaleIfError). A stale hit may be served
+// while the value is refreshed in the background. A stale miss carries the old
+// value in Data so that it can be served if loading a fresh one fails.
+//
+// NotFound is set on a hit of a tombstone written by WriteMissing, and by
+// Fetch when its loader reports NotFoundError. Data is nil.
+//
+// Lease is set on a miss when the cache is built WithLeases, and should be
+// passed back with WithLease when filling the key.
 type ReadCacheResult[T any] struct {
 	Data     *T
 	CacheHit bool
+	Stale    bool
+	NotFound bool
+	Lease    *Lease
+}
+
+// ReadItem reads key. opts apply to the lease handed out with a miss.
+func (c *Cache[T]) ReadItem(ctx context.Context, key string, opts ...ReadOpt) (result ReadCacheResult[T], err error) {
+	ctx, end := c.begin(ctx, opRead, attribute.String("cache.key", key))
+	defer end(&err)
+
+	result, err = c.read(ctx, key)
+	if err != nil {
+		return result, err
+	}
+	c.recordRead(result)
+	traceRead(ctx, result)
+	if !result.CacheHit {
+		result.Lease = c.AcquireLease(ctx, key, opts...)
+	}
+	return result, nil
 }
 
-func (c *Cache[T]) ReadItem(ctx context.Context, key string) (ReadCacheResult[T], error) {
-	cmd := c.redisClient.Get(ctx, key)
-	err := cmd.Err()
-	if errors.Is(err, redis.Nil) {
+// ReadItems reads every key in one call to the backend and returns a result per
+// key, misses included. Unlike ReadItem it does not acquire leases for misses.
+func (c *Cache[T]) ReadItems(ctx context.Context, keys ...string) (results map[string]ReadCacheResult[T], err error) {
+	results = make(map[string]ReadCacheResult[T], len(keys))
+	if len(keys) == 0 {
+		return results, nil
+	}
+	ctx, end := c.begin(ctx, opReadMany, attribute.Int("cache.keys", len(keys)))
+	defer end(&err)
+
+	backendKeys := make([]string, len(keys))
+	for i, key := range keys {
+		backendKeys[i] = c.key(key)
+	}
+	values, err := c.backend.MGet(ctx, backendKeys...)
+	if unavailable(err) {
+		values, err = make([][]byte, len(keys)), nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	entries := make(map[string]*entry[T], len(keys))
+	var tags []string
+	var undecodable []string
+	var decodeErr error
+	for i, key := range keys {
+		if values[i] == nil {
+			continue
+		}
+		e := new(entry[T])
+		err := c.unmarshal(backendKeys[i], values[i], e)
+		if err != nil {
+			// A corrupt entry only costs its key a miss.
+			undecodable = append(undecodable, key)
+			decodeErr = err
+			continue
+		}
+		entries[key] = e
+		tags = append(tags, e.tagNames()...)
+	}
+	if len(undecodable) > 0 {
+		c.dropUndecodable(ctx, decodeErr, undecodable...)
+	}
+
+	// The tags of every entry are checked in one more call.
+	versions, err := c.tagVersions(ctx, tags)
+	if unavailable(err) {
+		// Tagged entries cannot be checked, so only untagged ones are hits.
+		for key, e := range entries {
+			if len(e.Tags) > 0 {
+				delete(entries, key)
+			}
+		}
+		versions, err = nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	for _, key := range keys {
+		e, ok := entries[key]
+		if !ok {
+			results[key] = ReadCacheResult[T]{CacheHit: false}
+			continue
+		}
+		results[key] = c.decode(ctx, key, e, versions)
+	}
+	hits := 0
+	for _, result := range results {
+		c.recordRead(result)
+		if result.CacheHit {
+			hits++
+		}
+	}
+	trace.SpanFromContext(ctx).SetAttributes(attribute.Int("cache.hits", hits))
+	return results, nil
+}
+
+func (c *Cache[T]) read(ctx context.Context, key string) (ReadCacheResult[T], error) {
+	_, span := tracer().Start(ctx, "cache.backend.get")
+	b, err := c.backend.Get(ctx, c.key(key))
+	span.End()
+	if errors.Is(err, MissError) {
 		return ReadCacheResult[T]{
 			Data:     nil,
 			CacheHit: false,
 		}, nil
 	}
-
-	b, err := cmd.Bytes()
 	if err != nil {
 		return ReadCacheResult[T]{
 			CacheHit: false,
 		}, err
 	}
 
-	data := new(T)
+	e := new(entry[T])
+	_, span = tracer().Start(ctx, "cache.unmarshal", trace.WithAttributes(
+		attribute.Int("cache.value_bytes", len(b)),
+	))
+	err = c.unmarshal(c.key(key), b, e)
+	span.End()
+	if err != nil {
+		// Like a corrupt entry in ReadItems, it only costs the key a miss.
+		c.dropUndecodable(ctx, err, key)
+		return ReadCacheResult[T]{
+			CacheHit: false,
+		}, nil
+	}
 
-	err = json.Unmarshal(b, data)
+	versions, err := c.tagVersions(ctx, e.tagNames())
+	if unavailable(err) {
+		// The entry cannot be checked against its tags, which may have been
+		// invalidated.
+		return ReadCacheResult[T]{
+			CacheHit: false,
+		}, nil
+	}
 	if err != nil {
 		return ReadCacheResult[T]{
-			CacheHit: true,
+			CacheHit: false,
 		}, err
 	}
+	return c.decode(ctx, key, e, versions), nil
+}
+
+// dropUndecodable logs that the entries of keys do not decode, for instance
+// because they are encrypted with a key that has left the keyring, and deletes
+// them so that the next fill replaces them. The deletion is best effort: until
+// it succeeds the keys keep reading as misses.
+func (c *Cache[T]) dropUndecodable(ctx context.Context, err error, keys ...string) {
+	slog.Error("decoding cache entry",
+		slog.Any("error", err),
+		slog.Any("keys", keys),
+	)
+	backendKeys := make([]string, len(keys))
+	for i, key := range keys {
+		backendKeys[i] = c.key(key)
+	}
+	err = c.backend.Delete(ctx, backendKeys...)
+	if err != nil {
+		slog.Error("deleting undecodable cache entry",
+			slog.Any("error", err),
+			slog.Any("keys", keys),
+		)
+	}
+}
+
+// unavailable reports whether err means the backend was not asked at all or
+// did not answer in time. Reads treat it as a miss, like a Get the backend
+// rejected or that timed out.
+func unavailable(err error) bool {
+	return errors.Is(err, CircuitOpenError) || errors.Is(err, TimeoutError)
+}
+
+// decode turns a stored entry into a result, given the current versions of
+// its tags.
+func (c *Cache[T]) decode(ctx context.Context, key string, e *entry[T], versions map[string]int64) ReadCacheResult[T] {
+	if !e.current(versions) {
+		// One of its tags was invalidated.
+		return ReadCacheResult[T]{
+			Data:     nil,
+			CacheHit: false,
+		}
+	}
+
+	if c.opts.sliding && c.opts.defaultTTL > 0 {
+		// Refreshing the TTL is best effort, the read itself succeeded.
+		err := c.backend.Expire(ctx, c.key(key), c.opts.expiration(c.opts.defaultTTL))
+		if err != nil {
+			slog.Error("sliding expiration",
+				slog.Any("error", err),
+				slog.String("key", key),
+			)
+		}
+	}
+
+	if e.Missing {
+		return ReadCacheResult[T]{
+			CacheHit: true,
+			NotFound: true,
+		}
+	}
+
+	// Entries written before values were wrapped in an entry have no value.
+	if e.Value == nil {
+		return ReadCacheResult[T]{
+			Data:     nil,
+			CacheHit: false,
+		}
+	}
+
+	// Sliding entries only expire once they stop being read, so they are
+	// neither stale nor recomputed early.
+	if c.opts.sliding {
+		return ReadCacheResult[T]{
+			Data:     e.Value,
+			CacheHit: true,
+		}
+	}
+
+	now := c.now()
+	if e.stale(now) {
+		if e.stale(now.Add(-c.opts.staleWindow())) {
+			// Past every stale window, the backend is about to drop it.
+			return ReadCacheResult[T]{
+				Data:     nil,
+				CacheHit: false,
+			}
+		}
+		return ReadCacheResult[T]{
+			Data:     e.Value,
+			CacheHit: !e.stale(now.Add(-c.opts.staleWhileRevalidate)),
+			Stale:    true,
+		}
+	}
+
+	if e.recomputeEarly(now, c.opts.beta) {
+		return ReadCacheResult[T]{
+			Data:     nil,
+			CacheHit: false,
+		}
+	}
 
 	return ReadCacheResult[T]{
-		Data:     data,
+		Data:     e.Value,
 		CacheHit: true,
+	}
+}
+
+// Get reads key, loading it with the cache's Loader on a miss.
+func (c *Cache[T]) Get(ctx context.Context, key string) (ReadCacheResult[T], error) {
+	if c.loader == nil {
+		return ReadCacheResult[T]{}, NoLoaderError
+	}
+	return c.Fetch(ctx, key, c.loader)
+}
+
+// Fetch reads key and, on a miss, loads it with loader and writes it to the
+// cache before returning. It is the read-through counterpart of ReadItem for
+// callers whose loader depends on more than the key. Concurrent misses for the
+// same key share a single load and fill, see Coalesce, so key must identify
+// everything the loader reads: callers with different loaders for one key
+// would be handed each other's results.
+//
+// A stale hit is returned right away and refreshed in the background. If
+// loading fails while a stale value is still within WithStaleIfError, the
+// stale value is returned instead of the error. A read the backend fails is
+// logged and loaded like a miss.
+//
+// A key the loader reports as NotFoundError is cached as a tombstone, and is
+// returned as a result with NotFound set rather than as an error.
+//
+// opts apply to the write of a loaded value or tombstone, WithLease and
+// WithComputeCost excepted. The lease a miss is filled under records the
+// versions of its WithTags tags, see WithLeaseTags.
+func (c *Cache[T]) Fetch(ctx context.Context, key string, loader Loader[T], opts ...WriteOpt) (ReadCacheResult[T], error) {
+	o := c.writeOptions(opts)
+	result, err := c.ReadItem(ctx, key, WithLeaseTags(o.tags...))
+	if err != nil {
+		// The loader still has the value, so a failing backend only costs a
+		// miss.
+		slog.Error("read through cache read",
+			slog.Any("error", err),
+			slog.String("key", key),
+		)
+		result = ReadCacheResult[T]{}
+	}
+	if result.CacheHit {
+		if result.Stale {
+			c.revalidate(ctx, key, loader, opts)
+		}
+		return result, nil
+	}
+
+	data, err := c.Coalesce(ctx, key, c.fill(key, loader, result.Lease, opts))
+	if errors.Is(err, NotFoundError) {
+		return ReadCacheResult[T]{
+			CacheHit: false,
+			NotFound: true,
+		}, nil
+	}
+	if err != nil {
+		if result.Stale {
+			slog.Warn("serving stale cache entry",
+				slog.Any("error", err),
+				slog.String("key", key),
+			)
+			return result, nil
+		}
+		return ReadCacheResult[T]{}, err
+	}
+
+	return ReadCacheResult[T]{
+		Data:     data,
+		CacheHit: false,
 	}, nil
 }
+
+// revalidate refreshes key in the background. Concurrent revalidations of the
+// same key share one load.
+func (c *Cache[T]) revalidate(ctx context.Context, key string, loader Loader[T], opts []WriteOpt) {
+	ctx = context.WithoutCancel(ctx)
+	go func() {
+		lease := c.AcquireLease(ctx, key, WithLeaseTags(c.writeOptions(opts).tags...))
+		if lease != nil && !lease.Granted() {
+			// Someone else is already filling the key.
+			return
+		}
+
+		_, err := c.Coalesce(ctx, key, c.fill(key, loader, lease, opts))
+		if err != nil {
+			slog.Error("cache revalidation",
+				slog.Any("error", err),
+				slog.String("key", key),
+			)
+		}
+	}()
+}
+
+// fill returns a load that reads key with loader and writes it to the cache
+// under lease, with opts.
+func (c *Cache[T]) fill(key string, loader Loader[T], lease *Lease, opts []WriteOpt) func(ctx context.Context) (*T, error) {
+	return func(ctx context.Context) (*T, error) {
+		start := c.now()
+		loadCtx, end := c.begin(ctx, opLoad, attribute.String("cache.key", key))
+		data, err := loader(loadCtx, key)
+		end(&err)
+		if errors.Is(err, NotFoundError) {
+			fillErr := c.WriteMissing(ctx, key, append(opts[:len(opts):len(opts)], WithLease(lease))...)
+			if fillErr != nil && !errors.Is(fillErr, LeaseLostError) {
+				slog.Error("read through cache tombstone",
+					slog.Any("error", fillErr),
+					slog.String("key", key),
+				)
+			}
+			return nil, err
+		}
+		if err != nil {
+			// Let the next reader that misses fill the key.
+			releaseErr := c.ReleaseLease(context.WithoutCancel(ctx), key, lease)
+			if releaseErr != nil {
+				slog.Error("read through lease release",
+					slog.Any("error", releaseErr),
+					slog.String("key", key),
+				)
+			}
+			return nil, err
+		}
+
+		// The value was loaded, so a failed fill only costs the next read a
+		// miss.
+		err = c.WriteItem(ctx, key, data, append(opts[:len(opts):len(opts)],
+			WithComputeCost(c.now().Sub(start)),
+			WithLease(lease),
+		)...)
+		if err != nil && !errors.Is(err, LeaseLostError) {
+			slog.Error("read through cache fill",
+				slog.Any("error", err),
+				slog.String("key", key),
+			)
+		}
+		return data, nil
+	}
+}
+
+// Coalesce runs load once for every caller that misses key at the same time
+// and hands each of them its result. load gets a context that is only
+// cancelled once every waiting caller's context is done, so one cancelled
+// caller does not fail the others.
+func (c *Cache[T]) Coalesce(ctx context.Context, key string, load func(ctx context.Context) (*T, error)) (*T, error) {
+	return c.flights.do(ctx, key, load)
+}
@@ -0,0 +1,290 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+type testItem struct {
+	Name string `json:"name"`
+}
+
+func TestCache_ReadItem(t *testing.T) {
+	ctx := context.Background()
+	b, clock := newTestMemoryBackend()
+	c := New[testItem](b, WithDefaultTTL(time.Minute))
+
+	result, err := c.ReadItem(ctx, "a")
+	assert.NoError(t, err)
+	assert.False(t, result.CacheHit)
+
+	assert.NoError(t, c.WriteItem(ctx, "a", &testItem{Name: "a"}))
+	result, err = c.ReadItem(ctx, "a")
+	assert.NoError(t, err)
+	assert.True(t, result.CacheHit)
+	assert.Equal(t, &testItem{Name: "a"}, result.Data)
+
+	clock.Advance(time.Minute)
+	result, err = c.ReadItem(ctx, "a")
+	assert.NoError(t, err)
+	assert.False(t, result.CacheHit)
+}
+
+func TestCache_WriteItemTTL(t *testing.T) {
+	ctx := context.Background()
+	b, clock := newTestMemoryBackend()
+	c := New[testItem](b, WithDefaultTTL(time.Minute))
+
+	assert.NoError(t, c.WriteItem(ctx, "a", &testItem{Name: "a"}, WithTTL(time.Hour)))
+	clock.Advance(time.Minute)
+	result, err := c.ReadItem(ctx, "a")
+	assert.NoError(t, err)
+	assert.True(t, result.CacheHit)
+}
+
+func TestCache_SlidingExpiration(t *testing.T) {
+	ctx := context.Background()
+	b, clock := newTestMemoryBackend()
+	c := New[testItem](b, WithDefaultTTL(time.Minute), WithSlidingExpiration())
+
+	assert.NoError(t, c.WriteItem(ctx, "a", &testItem{Name: "a"}))
+	for range 3 {
+		clock.Advance(45 * time.Second)
+		result, err := c.ReadItem(ctx, "a")
+		assert.NoError(t, err)
+		assert.True(t, result.CacheHit)
+	}
+}
+
+func TestCache_Get(t *testing.T) {
+	ctx := context.Background()
+	loads := 0
+	loader := func(ctx context.Context, key string) (*testItem, error) {
+		loads++
+		if key == "broken" {
+			return nil, errors.New("source unavailable")
+		}
+		return &testItem{Name: key}, nil
+	}
+	c := NewReadThrough[testItem](NewMemoryBackend(), loader)
+
+	result, err := c.Get(ctx, "a")
+	assert.NoError(t, err)
+	assert.False(t, result.CacheHit)
+	assert.Equal(t, &testItem{Name: "a"}, result.Data)
+
+	result, err = c.Get(ctx, "a")
+	assert.NoError(t, err)
+	assert.True(t, result.CacheHit)
+	assert.Equal(t, 1, loads)
+
+	_, err = c.Get(ctx, "broken")
+	assert.Error(t, err)
+
+	_, err = New[testItem](NewMemoryBackend()).Get(ctx, "a")
+	assert.ErrorIs(t, err, NoLoaderError)
+}
+
+func TestCache_CountMiss(t *testing.T) {
+	ctx := context.Background()
+	b, clock := newTestMemoryBackend()
+	c := New[testItem](b)
+
+	for want := int64(1); want <= 3; want++ {
+		misses, err := c.CountMiss(ctx, "a", time.Minute)
+		assert.NoError(t, err)
+		assert.Equal(t, want, misses)
+	}
+
+	clock.Advance(time.Minute)
+	misses, err := c.CountMiss(ctx, "a", time.Minute)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(1), misses)
+
+	assert.NoError(t, c.ResetMisses(ctx, "a"))
+	misses, err = c.CountMiss(ctx, "a", time.Minute)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(1), misses)
+}
+
+func TestCache_FetchStale(t *testing.T) {
+	ctx := context.Background()
+	b, clock := newTestMemoryBackend()
+	c := New[testItem](
+		b,
+		WithDefaultTTL(time.Minute),
+		WithStaleWhileRevalidate(time.Minute),
+		WithStaleIfError(10*time.Minute),
+	)
+	c.now = clock.Now
+
+	sourceErr := errors.New("source unavailable")
+	failing := func(ctx context.Context, key string) (*testItem, error) {
+		return nil, sourceErr
+	}
+	assert.NoError(t, c.WriteItem(ctx, "a", &testItem{Name: "old"}))
+
+	// Inside the stale-while-revalidate window the stale value is served
+	// and refreshed in the background.
+	clock.Advance(time.Minute + time.Second)
+	refreshed := make(chan struct{})
+	result, err := c.Fetch(ctx, "a", func(ctx context.Context, key string) (*testItem, error) {
+		defer close(refreshed)
+		return &testItem{Name: "new"}, nil
+	})
+	assert.NoError(t, err)
+	assert.True(t, result.CacheHit)
+	assert.True(t, result.Stale)
+	assert.Equal(t, &testItem{Name: "old"}, result.Data)
+
+	<-refreshed
+	assert.Eventually(t, func() bool {
+		result, err := c.ReadItem(ctx, "a")
+		return err == nil && !result.Stale && result.Data.Name == "new"
+	}, time.Second, time.Millisecond)
+
+	// Past stale-while-revalidate the value is only served if loading fails.
+	clock.Advance(5 * time.Minute)
+	result, err = c.Fetch(ctx, "a", failing)
+	assert.NoError(t, err)
+	assert.False(t, result.CacheHit)
+	assert.True(t, result.Stale)
+	assert.Equal(t, &testItem{Name: "new"}, result.Data)
+
+	// Past the hard TTL the error is returned.
+	clock.Advance(10 * time.Minute)
+	_, err = c.Fetch(ctx, "a", failing)
+	assert.ErrorIs(t, err, sourceErr)
+}
+
+func TestCache_FetchWithFailingBackend(t *testing.T) {
+	ctx := context.Background()
+	c := New[testItem](&flakyBackend{Backend: NewMemoryBackend(), down: true})
+
+	result, err := c.Fetch(ctx, "a", func(ctx context.Context, key string) (*testItem, error) {
+		return &testItem{Name: key}, nil
+	})
+	assert.NoError(t, err)
+	assert.False(t, result.CacheHit)
+	assert.Equal(t, &testItem{Name: "a"}, result.Data)
+}
+
+func TestCache_FetchNotFound(t *testing.T) {
+	ctx := context.Background()
+
+	tests := []struct {
+		desc          string
+		opts          []CacheOpt
+		expectedLoads int
+	}{
+		{
+			desc:          "tombstone is cached with a negative ttl",
+			opts:          []CacheOpt{WithNegativeTTL(time.Minute)},
+			expectedLoads: 1,
+		},
+		{
+			desc:          "misses are not cached without a negative ttl",
+			expectedLoads: 2,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.desc, func(t *testing.T) {
+			c := New[testItem](NewMemoryBackend(), tc.opts...)
+			loads := 0
+			loader := func(ctx context.Context, key string) (*testItem, error) {
+				loads++
+				return nil, fmt.Errorf("%s: %w", key, NotFoundError)
+			}
+
+			for range 2 {
+				result, err := c.Fetch(ctx, "a", loader)
+				assert.NoError(t, err)
+				assert.True(t, result.NotFound)
+				assert.Nil(t, result.Data)
+			}
+			assert.Equal(t, tc.expectedLoads, loads)
+		})
+	}
+}
+
+func TestCache_Batch(t *testing.T) {
+	ctx := context.Background()
+	b, clock := newTestMemoryBackend()
+	c := New[testItem](b, WithDefaultTTL(time.Minute), WithNegativeTTL(time.Minute), WithLeases(time.Second))
+
+	assert.NoError(t, c.WriteItems(ctx, map[string]*testItem{
+		"a": {Name: "a"},
+		"b": {Name: "b"},
+	}))
+	assert.NoError(t, c.WriteMissing(ctx, "c"))
+
+	results, err := c.ReadItems(ctx, "a", "b", "c", "d")
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]ReadCacheResult[testItem]{
+		"a": {Data: &testItem{Name: "a"}, CacheHit: true},
+		"b": {Data: &testItem{Name: "b"}, CacheHit: true},
+		"c": {CacheHit: true, NotFound: true},
+		"d": {CacheHit: false},
+	}, results)
+
+	assert.NoError(t, c.InvalidateKeys(ctx, "a", "c"))
+	results, err = c.ReadItems(ctx, "a", "b", "c")
+	assert.NoError(t, err)
+	assert.False(t, results["a"].CacheHit)
+	assert.True(t, results["b"].CacheHit)
+	assert.False(t, results["c"].CacheHit)
+
+	clock.Advance(time.Minute)
+	results, err = c.ReadItems(ctx, "b")
+	assert.NoError(t, err)
+	assert.False(t, results["b"].CacheHit)
+}
+
+func TestCache_WriteItemsCancelsLeases(t *testing.T) {
+	ctx := context.Background()
+	c := New[testItem](NewMemoryBackend(), WithLeases(time.Minute))
+
+	result, err := c.ReadItem(ctx, "a")
+	assert.NoError(t, err)
+	assert.True(t, result.Lease.Granted())
+
+	assert.NoError(t, c.WriteItems(ctx, map[string]*testItem{"a": {Name: "new"}}))
+	err = c.WriteItem(ctx, "a", &testItem{Name: "old"}, WithLease(result.Lease))
+	assert.ErrorIs(t, err, LeaseLostError)
+}
+
+func TestCache_Namespace(t *testing.T) {
+	ctx := context.Background()
+	b := NewMemoryBackend()
+	v1 := New[testItem](b, WithNamespace("item", 1))
+	v2 := New[testItem](b, WithNamespace("item", 2))
+	other := New[testItem](b, WithNamespace("other", 1))
+
+	assert.NoError(t, v1.WriteItem(ctx, "a", &testItem{Name: "a"}))
+	_, err := b.Get(ctx, "item:v1:a")
+	assert.NoError(t, err)
+
+	result, err := v1.ReadItem(ctx, "a")
+	assert.NoError(t, err)
+	assert.True(t, result.CacheHit)
+
+	for _, c := range []*Cache[testItem]{v2, other} {
+		result, err = c.ReadItem(ctx, "a")
+		assert.NoError(t, err)
+		assert.False(t, result.CacheHit)
+	}
+
+	// The loader is called with the key the caller used.
+	var loaded string
+	_, err = v2.Fetch(ctx, "a", func(ctx context.Context, key string) (*testItem, error) {
+		loaded = key
+		return &testItem{Name: key}, nil
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, "a", loaded)
+}
@@ -0,0 +1,268 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"sync"
+	"time"
+)
+
+var _ Backend = (*CircuitBreakerBackend)(nil)
+
+var CircuitOpenError = errors.New("cache circuit breaker is open")
+
+type circuitState int
+
+const (
+	circuitClosed circuitState = iota
+	circuitOpen
+	circuitHalfOpen
+)
+
+type circuitBreakerOptions struct {
+	failureThreshold int
+	openTimeout      time.Duration
+}
+
+type CircuitBreakerOpt func(o *circuitBreakerOptions)
+
+// WithFailureThreshold sets how many consecutive failures open the circuit.
+func WithFailureThreshold(failures int) CircuitBreakerOpt {
+	return func(o *circuitBreakerOptions) {
+		o.failureThreshold = failures
+	}
+}
+
+// WithOpenTimeout sets how long the circuit stays open before a request is let
+// through to probe the backend.
+func WithOpenTimeout(timeout time.Duration) CircuitBreakerOpt {
+	return func(o *circuitBreakerOptions) {
+		o.openTimeout = timeout
+	}
+}
+
+// CircuitBreakerBackend stops a failing backend, typically Redis, from failing
+// every request made through the cache. After a run of consecutive failures the
+// circuit opens: reads are misses and writes are dropped without reaching the
+// backend, so callers fall back to the source of truth. Once the open timeout
+// has passed, a single request probes the backend, and closes the circuit if
+// it succeeds.
+//
+// Calls whose result cannot be made up while the circuit is open fail with
+// CircuitOpenError instead: MGet, whose nil values would read as tags that were
+// never invalidated, and Delete and Incr, so that invalidations report that
+// they did not happen. They are not replayed, so entries invalidated during an
+// outage may be served until their TTL expires.
+type CircuitBreakerBackend struct {
+	backend Backend
+	opts    circuitBreakerOptions
+	now     func() time.Time
+
+	mu       sync.Mutex
+	state    circuitState
+	failures int
+	openedAt time.Time
+}
+
+func NewCircuitBreakerBackend(backend Backend, opts ...CircuitBreakerOpt) *CircuitBreakerBackend {
+	o := circuitBreakerOptions{
+		failureThreshold: 5,
+		openTimeout:      10 * time.Second,
+	}
+	for _, opt := range opts {
+		opt(&o)
+	}
+	return &CircuitBreakerBackend{
+		backend: backend,
+		opts:    o,
+		now:     time.Now,
+	}
+}
+
+// allow reports whether a request may reach the backend. When the open
+// timeout has passed, the request it allows is the probe.
+func (b *CircuitBreakerBackend) allow() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	switch b.state {
+	case circuitClosed:
+		return true
+	case circuitOpen:
+		if b.now().Sub(b.openedAt) < b.opts.openTimeout {
+			return false
+		}
+		b.state = circuitHalfOpen
+		return true
+	default:
+		// A probe is already in flight.
+		return false
+	}
+}
+
+// done records the outcome of a request allow let through. A request cancelled
+// by its caller says nothing about the backend, so it counts as neither a
+// success nor a failure; if it was the probe, the next request probes again.
+// The attempts of a call retried by WithRetries count as one failure.
+func (b *CircuitBreakerBackend) done(ctx context.Context, err error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if errors.Is(err, context.Canceled) {
+		if b.state == circuitHalfOpen {
+			b.state = circuitOpen
+		}
+		return
+	}
+
+	failed := err != nil && !errors.Is(err, MissError)
+	if !failed {
+		b.failures = 0
+		if b.state != circuitClosed {
+			slog.Info("cache circuit breaker closed")
+			b.state = circuitClosed
+		}
+		return
+	}
+
+	if call, ok := ctx.Value(retriedCallKey{}).(*retriedCall); ok {
+		if call.failed {
+			return
+		}
+		call.failed = true
+	}
+	b.failures++
+	if b.state == circuitHalfOpen || b.failures >= b.opts.failureThreshold {
+		b.openedAt = b.now()
+		if b.state != circuitOpen {
+			slog.Warn("cache circuit breaker opened",
+				slog.Any("error", err),
+				slog.Int("failures", b.failures),
+			)
+			b.state = circuitOpen
+		}
+	}
+}
+
+// retriedCall marks the context of the attempts of one call retried by a
+// policyBackend, so that a CircuitBreakerBackend beneath it counts them as a
+// single failure. It is guarded by the breaker's mu.
+type retriedCall struct {
+	failed bool
+}
+
+type retriedCallKey struct{}
+
+// Open reports whether requests are currently kept from the backend.
+func (b *CircuitBreakerBackend) Open() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.state != circuitClosed
+}
+
+func (b *CircuitBreakerBackend) Get(ctx context.Context, key string) ([]byte, error) {
+	if !b.allow() {
+		return nil, MissError
+	}
+	value, err := b.backend.Get(ctx, key)
+	b.done(ctx, err)
+	return value, err
+}
+
+func (b *CircuitBreakerBackend) MGet(ctx context.Context, keys ...string) ([][]byte, error) {
+	if !b.allow() {
+		return nil, CircuitOpenError
+	}
+	values, err := b.backend.MGet(ctx, keys...)
+	b.done(ctx, err)
+	return values, err
+}
+
+func (b *CircuitBreakerBackend) Set(ctx context.Context, key string, value []byte, ttl time.Duration) error {
+	if !b.allow() {
+		return nil
+	}
+	err := b.backend.Set(ctx, key, value, ttl)
+	b.done(ctx, err)
+	return err
+}
+
+func (b *CircuitBreakerBackend) MSet(ctx context.Context, items ...KeyValue) error {
+	if !b.allow() {
+		return nil
+	}
+	err := b.backend.MSet(ctx, items...)
+	b.done(ctx, err)
+	return err
+}
+
+func (b *CircuitBreakerBackend) Delete(ctx context.Context, keys ...string) error {
+	if !b.allow() {
+		return CircuitOpenError
+	}
+	err := b.backend.Delete(ctx, keys...)
+	b.done(ctx, err)
+	return err
+}
+
+func (b *CircuitBreakerBackend) Expire(ctx context.Context, key string, ttl time.Duration) error {
+	if !b.allow() {
+		return nil
+	}
+	err := b.backend.Expire(ctx, key, ttl)
+	b.done(ctx, err)
+	return err
+}
+
+func (b *CircuitBreakerBackend) Incr(ctx context.Context, key string) (int64, error) {
+	if !b.allow() {
+		return 0, CircuitOpenError
+	}
+	n, err := b.backend.Incr(ctx, key)
+	b.done(ctx, err)
+	return n, err
+}
+
+// Lease is never granted while the circuit is open, so readers go to the
+// source of truth without filling the cache.
+func (b *CircuitBreakerBackend) Lease(ctx context.Context, leaseKey string, token string, ttl time.Duration) (bool, error) {
+	if !b.allow() {
+		return false, nil
+	}
+	granted, err := b.backend.Lease(ctx, leaseKey, token, ttl)
+	b.done(ctx, err)
+	return granted, err
+}
+
+func (b *CircuitBreakerBackend) SetLeased(
+	ctx context.Context,
+	key string,
+	leaseKey string,
+	token string,
+	value []byte,
+	ttl time.Duration,
+) (bool, error) {
+	if !b.allow() {
+		return false, nil
+	}
+	set, err := b.backend.SetLeased(ctx, key, leaseKey, token, value, ttl)
+	b.done(ctx, err)
+	return set, err
+}
+
+func (b *CircuitBreakerBackend) MLease(ctx context.Context, leases ...KeyValue) ([]bool, error) {
+	if !b.allow() {
+		return make([]bool, len(leases)), nil
+	}
+	granted, err := b.backend.MLease(ctx, leases...)
+	b.done(ctx, err)
+	return granted, err
+}
+
+func (b *CircuitBreakerBackend) MSetLeased(ctx context.Context, items ...LeasedKeyValue) ([]bool, error) {
+	if !b.allow() {
+		return make([]bool, len(items)), nil
+	}
+	set, err := b.backend.MSetLeased(ctx, items...)
+	b.done(ctx, err)
+	return set, err
+}
@@ -0,0 +1,289 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"strings"
+	"testing"
+	"time"
+)
+
+var errBackendDown = errors.New("backend down")
+
+// flakyBackend fails every request while down is set, and counts the requests
+// that reached it. Reads of tag versions fail with tagErr when it is set.
+type flakyBackend struct {
+	Backend
+	down   bool
+	tagErr error
+	calls  int
+}
+
+func (b *flakyBackend) Get(ctx context.Context, key string) ([]byte, error) {
+	b.calls++
+	if b.down {
+		return nil, errBackendDown
+	}
+	return b.Backend.Get(ctx, key)
+}
+
+func (b *flakyBackend) MGet(ctx context.Context, keys ...string) ([][]byte, error) {
+	b.calls++
+	if b.down {
+		return nil, errBackendDown
+	}
+	if b.tagErr != nil && strings.HasPrefix(keys[0], "tag:") {
+		return nil, b.tagErr
+	}
+	return b.Backend.MGet(ctx, keys...)
+}
+
+func (b *flakyBackend) Set(ctx context.Context, key string, value []byte, ttl time.Duration) error {
+	b.calls++
+	if b.down {
+		return errBackendDown
+	}
+	return b.Backend.Set(ctx, key, value, ttl)
+}
+
+func newTestCircuitBreaker() (*CircuitBreakerBackend, *flakyBackend, *fakeClock) {
+	flaky := &flakyBackend{Backend: NewMemoryBackend()}
+	clock := &fakeClock{now: time.Now()}
+	b := NewCircuitBreakerBackend(flaky, WithFailureThreshold(3), WithOpenTimeout(time.Second))
+	b.now = clock.Now
+	return b, flaky, clock
+}
+
+func TestCircuitBreakerBackend(t *testing.T) {
+	ctx := context.Background()
+
+	tests := []struct {
+		desc string
+		run  func(t *testing.T, b *CircuitBreakerBackend, flaky *flakyBackend, clock *fakeClock)
+	}{
+		{
+			desc: "errors are returned until the threshold opens the circuit",
+			run: func(t *testing.T, b *CircuitBreakerBackend, flaky *flakyBackend, clock *fakeClock) {
+				flaky.down = true
+				for range 3 {
+					_, err := b.Get(ctx, "a")
+					assert.ErrorIs(t, err, errBackendDown)
+				}
+				assert.True(t, b.Open())
+
+				_, err := b.Get(ctx, "a")
+				assert.ErrorIs(t, err, MissError)
+				assert.NoError(t, b.Set(ctx, "a", []byte("1"), 0))
+				assert.Equal(t, 3, flaky.calls)
+			},
+		},
+		{
+			desc: "misses and successes reset the failure count",
+			run: func(t *testing.T, b *CircuitBreakerBackend, flaky *flakyBackend, clock *fakeClock) {
+				for range 3 {
+					flaky.down = true
+					_, err := b.Get(ctx, "a")
+					assert.ErrorIs(t, err, errBackendDown)
+					_, err = b.Get(ctx, "a")
+					assert.ErrorIs(t, err, errBackendDown)
+
+					flaky.down = false
+					_, err = b.Get(ctx, "a")
+					assert.ErrorIs(t, err, MissError)
+				}
+				assert.False(t, b.Open())
+			},
+		},
+		{
+			desc: "a successful probe closes the circuit",
+			run: func(t *testing.T, b *CircuitBreakerBackend, flaky *flakyBackend, clock *fakeClock) {
+				flaky.down = true
+				for range 3 {
+					_ = b.Set(ctx, "a", []byte("1"), 0)
+				}
+				flaky.down = false
+				clock.Advance(time.Second)
+
+				assert.NoError(t, b.Set(ctx, "a", []byte("1"), 0))
+				assert.False(t, b.Open())
+				value, err := b.Get(ctx, "a")
+				assert.NoError(t, err)
+				assert.Equal(t, []byte("1"), value)
+			},
+		},
+		{
+			desc: "a failed probe keeps the circuit open for another timeout",
+			run: func(t *testing.T, b *CircuitBreakerBackend, flaky *flakyBackend, clock *fakeClock) {
+				flaky.down = true
+				for range 3 {
+					_ = b.Set(ctx, "a", []byte("1"), 0)
+				}
+				clock.Advance(time.Second)
+
+				_, err := b.Get(ctx, "a")
+				assert.ErrorIs(t, err, errBackendDown)
+				assert.True(t, b.Open())
+
+				clock.Advance(time.Second / 2)
+				_, err = b.Get(ctx, "a")
+				assert.ErrorIs(t, err, MissError)
+				assert.Equal(t, 4, flaky.calls)
+			},
+		},
+		{
+			desc: "calls that cannot be answered fail while the circuit is open",
+			run: func(t *testing.T, b *CircuitBreakerBackend, flaky *flakyBackend, clock *fakeClock) {
+				flaky.down = true
+				for range 3 {
+					_ = b.Set(ctx, "a", []byte("1"), 0)
+				}
+
+				_, err := b.MGet(ctx, "a")
+				assert.ErrorIs(t, err, CircuitOpenError)
+				assert.ErrorIs(t, b.Delete(ctx, "a"), CircuitOpenError)
+				_, err = b.Incr(ctx, "a")
+				assert.ErrorIs(t, err, CircuitOpenError)
+				assert.Equal(t, 3, flaky.calls)
+			},
+		},
+		{
+			desc: "a cancelled probe leaves the circuit open for the next probe",
+			run: func(t *testing.T, b *CircuitBreakerBackend, flaky *flakyBackend, clock *fakeClock) {
+				flaky.down = true
+				for range 3 {
+					_ = b.Set(ctx, "a", []byte("1"), 0)
+				}
+				clock.Advance(time.Second)
+
+				assert.True(t, b.allow())
+				b.done(ctx, context.Canceled)
+				assert.True(t, b.Open())
+
+				flaky.down = false
+				assert.NoError(t, b.Set(ctx, "a", []byte("1"), 0))
+				assert.False(t, b.Open())
+			},
+		},
+		{
+			desc: "cancelled requests do not count as failures",
+			run: func(t *testing.T, b *CircuitBreakerBackend, flaky *flakyBackend, clock *fakeClock) {
+				cancelled, cancel := context.WithCancel(ctx)
+				cancel()
+				for range 3 {
+					b.done(cancelled, cancelled.Err())
+				}
+				assert.False(t, b.Open())
+			},
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.desc, func(t *testing.T) {
+			b, flaky, clock := newTestCircuitBreaker()
+			tc.run(t, b, flaky, clock)
+		})
+	}
+}
+
+func TestCache_CircuitBreaker(t *testing.T) {
+	ctx := context.Background()
+	b, flaky, _ := newTestCircuitBreaker()
+	c := New[testItem](b)
+
+	flaky.down = true
+	for range 3 {
+		_, err := c.ReadItem(ctx, "a")
+		assert.Error(t, err)
+	}
+
+	result, err := c.ReadItem(ctx, "a")
+	assert.NoError(t, err)
+	assert.False(t, result.CacheHit)
+	assert.NoError(t, c.WriteItem(ctx, "a", &testItem{Name: "a"}))
+	assert.ErrorIs(t, c.InvalidateKey(ctx, "a"), CircuitOpenError)
+	assert.ErrorIs(t, c.InvalidateTag(ctx, "user:1"), CircuitOpenError)
+}
+
+func TestCache_UnavailableTagVersions(t *testing.T) {
+	ctx := context.Background()
+	flaky := &flakyBackend{Backend: NewMemoryBackend()}
+	c := New[testItem](flaky)
+
+	assert.NoError(t, c.WriteItem(ctx, "a", &testItem{Name: "a"}, WithTags("user:1")))
+	assert.NoError(t, c.WriteItem(ctx, "b", &testItem{Name: "b"}))
+	assert.NoError(t, c.InvalidateTag(ctx, "user:1"))
+	flaky.tagErr = CircuitOpenError
+
+	result, err := c.ReadItem(ctx, "a")
+	assert.NoError(t, err)
+	assert.False(t, result.CacheHit, "an entry whose tags cannot be checked should miss")
+
+	results, err := c.ReadItems(ctx, "a", "b")
+	assert.NoError(t, err)
+	assert.False(t, results["a"].CacheHit)
+	assert.True(t, results["b"].CacheHit, "an untagged entry needs no check")
+}
+
+func TestCache_RetriesWithCircuitBreaker(t *testing.T) {
+	ctx := context.Background()
+
+	tests := []struct {
+		desc string
+		run  func(t *testing.T, b *CircuitBreakerBackend, flaky *flakyBackend)
+	}{
+		{
+			desc: "the attempts of a retried call count as one failure",
+			run: func(t *testing.T, b *CircuitBreakerBackend, flaky *flakyBackend) {
+				c := New[testItem](b, WithRetries(2, 0))
+				flaky.down = true
+
+				_, err := c.ReadItem(ctx, "a")
+				assert.ErrorIs(t, err, errBackendDown)
+				assert.Equal(t, 3, flaky.calls)
+				assert.False(t, b.Open())
+
+				_, err = c.ReadItem(ctx, "a")
+				assert.ErrorIs(t, err, errBackendDown)
+				// The first attempt of the third call opens the circuit, and
+				// its retries read as misses.
+				result, err := c.ReadItem(ctx, "a")
+				assert.NoError(t, err)
+				assert.False(t, result.CacheHit)
+				assert.True(t, b.Open())
+				assert.Equal(t, 7, flaky.calls)
+			},
+		},
+		{
+			desc: "an open circuit is not retried",
+			run: func(t *testing.T, b *CircuitBreakerBackend, flaky *flakyBackend) {
+				flaky.down = true
+				for range 3 {
+					_ = b.Set(ctx, "a", []byte("1"), 0)
+				}
+				require.True(t, b.Open())
+
+				// The backoff would hold a retry for up to a minute.
+				c := New[testItem](b, WithRetries(2, time.Minute))
+				invalidated := make(chan error, 1)
+				go func() {
+					invalidated <- c.InvalidateKey(ctx, "a")
+				}()
+				select {
+				case err := <-invalidated:
+					assert.ErrorIs(t, err, CircuitOpenError)
+				case <-time.After(time.Second):
+					t.Fatal("the rejected call was retried")
+				}
+			},
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.desc, func(t *testing.T) {
+			b, flaky, _ := newTestCircuitBreaker()
+			tc.run(t, b, flaky)
+		})
+	}
+}
@@ -0,0 +1,167 @@
+package cache
+
+import (
+	"bytes"
+	"encoding/gob"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/vmihailenco/msgpack/v5"
+)
+
+var UnknownCodecError = errors.New("cache entry has an unknown codec header")
+
+// Codec serializes the entries a Cache stores in its backend.
+type Codec interface {
+	// ID identifies the codec in the header byte of every value it encodes,
+	// so that values keep decoding after a cache switches codecs. IDs up to 15
+	// are reserved for the built-in codecs and value flags.
+	ID() byte
+	Marshal(v any) ([]byte, error)
+	Unmarshal(data []byte, v any) error
+}
+
+const (
+	jsonCodecID byte = iota + 1
+	gobCodecID
+	msgPackCodecID
+)
+
+// builtinCodecs are the codecs every Cache can decode.
+var builtinCodecs = map[byte]Codec{
+	jsonCodecID:    JSONCodec{},
+	gobCodecID:     GobCodec{},
+	msgPackCodecID: MsgPackCodec{},
+}
+
+// JSONCodec is the default codec.
+type JSONCodec struct{}
+
+func (JSONCodec) ID() byte {
+	return jsonCodecID
+}
+
+func (JSONCodec) Marshal(v any) ([]byte, error) {
+	return json.Marshal(v)
+}
+
+func (JSONCodec) Unmarshal(data []byte, v any) error {
+	return json.Unmarshal(data, v)
+}
+
+// GobCodec encodes with encoding/gob. Every value carries its type
+// description, so it pays off for large values rather than small ones.
+type GobCodec struct{}
+
+func (GobCodec) ID() byte {
+	return gobCodecID
+}
+
+func (GobCodec) Marshal(v any) ([]byte, error) {
+	var buf bytes.Buffer
+	err := gob.NewEncoder(&buf).Encode(v)
+	if err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func (GobCodec) Unmarshal(data []byte, v any) error {
+	return gob.NewDecoder(bytes.NewReader(data)).Decode(v)
+}
+
+// MsgPackCodec encodes with MessagePack, a compact binary counterpart of JSON
+// that is smaller and faster to decode. Struct fields are named by their json
+// tags, so values need no extra tags.
+type MsgPackCodec struct{}
+
+func (MsgPackCodec) ID() byte {
+	return msgPackCodecID
+}
+
+func (MsgPackCodec) Marshal(v any) ([]byte, error) {
+	var buf bytes.Buffer
+	enc := msgpack.NewEncoder(&buf)
+	enc.SetCustomStructTag("json")
+	err := enc.Encode(v)
+	if err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func (MsgPackCodec) Unmarshal(data []byte, v any) error {
+	dec := msgpack.NewDecoder(bytes.NewReader(data))
+	dec.SetCustomStructTag("json")
+	return dec.Decode(v)
+}
+
+// marshal encodes v, to be stored under the backend key key, with the cache's
+// codec, behind its header byte, compresses it if it is large enough and
+// encrypts it if the cache is built WithEncryption.
+func (c *Cache[T]) marshal(key string, v any) ([]byte, error) {
+	b, err := c.opts.codec.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	encoded := append([]byte{c.opts.codec.ID()}, b...)
+
+	stored, err := c.compress(encoded)
+	if err != nil {
+		return nil, err
+	}
+	c.recordWrite(len(encoded), len(stored))
+
+	if c.opts.keyring != nil {
+		return c.opts.keyring.encrypt(key, stored)
+	}
+	return stored, nil
+}
+
+// unmarshal decodes a value read from the backend key key and written by
+// marshal with whichever codec its header names, so entries written before a
+// codec change still decode. A cache built WithEncryption rejects values that
+// are not encrypted unless it is also built WithPlaintextReads.
+func (c *Cache[T]) unmarshal(key string, b []byte, v any) error {
+	if len(b) == 0 {
+		return UnknownCodecError
+	}
+	if b[0] == encryptedFlag {
+		if c.opts.keyring == nil {
+			return UnknownKeyError
+		}
+		var err error
+		b, err = c.opts.keyring.decrypt(key, b)
+		if err != nil {
+			return err
+		}
+		if len(b) == 0 {
+			return UnknownCodecError
+		}
+	} else if c.opts.keyring != nil && !c.opts.plaintextReads {
+		return PlaintextError
+	}
+	if b[0] == gzipFlag {
+		var err error
+		b, err = decompress(b)
+		if err != nil {
+			return err
+		}
+		if len(b) == 0 {
+			return UnknownCodecError
+		}
+	}
+	// Entries written before values had a header are bare JSON objects.
+	if b[0] == '{' {
+		return json.Unmarshal(b, v)
+	}
+
+	codec, ok := builtinCodecs[b[0]]
+	if b[0] == c.opts.codec.ID() {
+		codec, ok = c.opts.codec, true
+	}
+	if !ok {
+		return fmt.Errorf("%w: %d", UnknownCodecError, b[0])
+	}
+	return codec.Unmarshal(b[1:], v)
+}
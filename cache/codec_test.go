@@ -0,0 +1,106 @@
+package cache
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+func TestCodecs(t *testing.T) {
+	ctx := context.Background()
+
+	tests := []struct {
+		desc  string
+		codec Codec
+	}{
+		{desc: "json", codec: JSONCodec{}},
+		{desc: "gob", codec: GobCodec{}},
+		{desc: "msgpack", codec: MsgPackCodec{}},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.desc, func(t *testing.T) {
+			b := NewMemoryBackend()
+			c := New[testItem](b, WithCodec(tc.codec), WithNegativeTTL(time.Minute))
+
+			assert.NoError(t, c.WriteItem(ctx, "a", &testItem{Name: "a"}, WithTags("t")))
+			assert.NoError(t, c.WriteMissing(ctx, "b"))
+			value, err := b.Get(ctx, "a")
+			assert.NoError(t, err)
+			assert.Equal(t, tc.codec.ID(), value[0])
+
+			results, err := c.ReadItems(ctx, "a", "b")
+			assert.NoError(t, err)
+			assert.Equal(t, &testItem{Name: "a"}, results["a"].Data)
+			assert.True(t, results["b"].NotFound)
+
+			// A cache moved to another codec still reads the old entries.
+			for _, other := range tests {
+				result, err := New[testItem](b, WithCodec(other.codec)).ReadItem(ctx, "a")
+				assert.NoError(t, err)
+				assert.Equal(t, &testItem{Name: "a"}, result.Data)
+			}
+		})
+	}
+}
+
+func TestCodecs_LegacyJSON(t *testing.T) {
+	ctx := context.Background()
+	b := NewMemoryBackend()
+	c := New[testItem](b, WithCodec(MsgPackCodec{}))
+
+	legacy, err := json.Marshal(&entry[testItem]{Value: &testItem{Name: "a"}})
+	assert.NoError(t, err)
+	assert.NoError(t, b.Set(ctx, "a", legacy, 0))
+	assert.NoError(t, b.Set(ctx, "b", []byte{0xff, 0x00}, 0))
+
+	result, err := c.ReadItem(ctx, "a")
+	assert.NoError(t, err)
+	assert.Equal(t, &testItem{Name: "a"}, result.Data)
+
+	// A value with an unknown header is a miss.
+	result, err = c.ReadItem(ctx, "b")
+	assert.NoError(t, err)
+	assert.False(t, result.CacheHit)
+}
+
+func BenchmarkCodecs(b *testing.B) {
+	type benchItem struct {
+		ID          string     `json:"id"`
+		Title       string     `json:"title"`
+		Description string     `json:"description"`
+		CreatedAt   *time.Time `json:"created_at"`
+		Tags        []string   `json:"tags"`
+	}
+	now := time.Now()
+	data := &benchItem{
+		ID:          "6f1c2b1e-6a53-4a9b-a3a1-2f0a1a9f4c11",
+		Title:       "write the report",
+		Description: "summarize the cache hit rates for the last quarter",
+		CreatedAt:   &now,
+		Tags:        []string{"work", "reports"},
+	}
+
+	for _, codec := range []Codec{JSONCodec{}, GobCodec{}, MsgPackCodec{}} {
+		c := New[benchItem](NewMemoryBackend(), WithCodec(codec))
+		encoded, err := c.marshal("a", newEntry(data, now, time.Minute, 0))
+		if err != nil {
+			b.Fatal(err)
+		}
+
+		b.Run(fmt.Sprintf("%T/encode", codec), func(b *testing.B) {
+			b.ReportMetric(float64(len(encoded)), "bytes")
+			for range b.N {
+				_, _ = c.marshal("a", newEntry(data, now, time.Minute, 0))
+			}
+		})
+		b.Run(fmt.Sprintf("%T/decode", codec), func(b *testing.B) {
+			for range b.N {
+				_ = c.unmarshal("a", encoded, new(entry[benchItem]))
+			}
+		})
+	}
+}
@@ -0,0 +1,57 @@
+package cache
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io"
+	"sync"
+)
+
+// gzipFlag prefixes values compressed with gzip. It is one of the header
+// bytes reserved for built-in codecs, so it is never mistaken for a codec.
+const gzipFlag byte = 15
+
+var gzipWriters = sync.Pool{
+	New: func() any {
+		w, _ := gzip.NewWriterLevel(nil, gzip.BestSpeed)
+		return w
+	},
+}
+
+// compress gzips b behind gzipFlag when b is at least the threshold set with
+// WithCompression. b is returned as is when it is smaller, or when
+// compressing does not make it smaller.
+func (c *Cache[T]) compress(b []byte) ([]byte, error) {
+	if c.opts.compressionThreshold <= 0 || len(b) < c.opts.compressionThreshold {
+		return b, nil
+	}
+
+	buf := bytes.NewBuffer(make([]byte, 0, len(b)/2))
+	buf.WriteByte(gzipFlag)
+	w := gzipWriters.Get().(*gzip.Writer)
+	defer gzipWriters.Put(w)
+	w.Reset(buf)
+	_, err := w.Write(b)
+	if err != nil {
+		return nil, err
+	}
+	err = w.Close()
+	if err != nil {
+		return nil, err
+	}
+
+	if buf.Len() >= len(b) {
+		return b, nil
+	}
+	return buf.Bytes(), nil
+}
+
+// decompress reverses compress for a value starting with gzipFlag.
+func decompress(b []byte) ([]byte, error) {
+	r, err := gzip.NewReader(bytes.NewReader(b[1:]))
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+	return io.ReadAll(r)
+}
@@ -0,0 +1,58 @@
+package cache
+
+import (
+	"context"
+	"github.com/stretchr/testify/assert"
+	"strings"
+	"testing"
+)
+
+func TestCache_Compression(t *testing.T) {
+	ctx := context.Background()
+
+	tests := []struct {
+		desc               string
+		name               string
+		expectedCompressed bool
+	}{
+		{
+			desc:               "value above the threshold is compressed",
+			name:               strings.Repeat("long description ", 64),
+			expectedCompressed: true,
+		},
+		{
+			desc:               "value below the threshold is stored as is",
+			name:               "short",
+			expectedCompressed: false,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.desc, func(t *testing.T) {
+			b := NewMemoryBackend()
+			c := New[testItem](b, WithCompression(256))
+
+			assert.NoError(t, c.WriteItem(ctx, "a", &testItem{Name: tc.name}))
+			value, err := b.Get(ctx, "a")
+			assert.NoError(t, err)
+			assert.Equal(t, tc.expectedCompressed, value[0] == gzipFlag)
+
+			// Any cache reads compressed values, whatever its threshold.
+			for _, reader := range []*Cache[testItem]{c, New[testItem](b)} {
+				result, err := reader.ReadItem(ctx, "a")
+				assert.NoError(t, err)
+				assert.Equal(t, &testItem{Name: tc.name}, result.Data)
+			}
+
+			stats := c.Stats()
+			assert.Equal(t, int64(1), stats.Writes)
+			if tc.expectedCompressed {
+				assert.Equal(t, int64(1), stats.CompressedWrites)
+				assert.Greater(t, stats.CompressionRatio(), 10.0)
+			} else {
+				assert.Equal(t, int64(0), stats.CompressedWrites)
+				assert.Equal(t, 1.0, stats.CompressionRatio())
+			}
+		})
+	}
+}
@@ -0,0 +1,107 @@
+package cache
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"errors"
+	"fmt"
+)
+
+var (
+	UnknownKeyError  = errors.New("cache entry is encrypted with an unknown key")
+	InvalidKeyError  = errors.New("invalid cache encryption key")
+	CorruptDataError = errors.New("cache entry is corrupt")
+	PlaintextError   = errors.New("cache entry is not encrypted")
+)
+
+// encryptedFlag prefixes values encrypted with AES-GCM. Like gzipFlag, it is
+// one of the header bytes reserved for built-in codecs.
+const encryptedFlag byte = 14
+
+// Keyring holds the AES keys cached values are encrypted with, by key ID.
+// Values are encrypted with the current key and carry its ID, so a key can be
+// rotated by making a new one current while keeping the old one until the
+// values written with it have expired.
+type Keyring struct {
+	current string
+	aeads   map[string]cipher.AEAD
+}
+
+// NewKeyring builds a keyring from AES-128, AES-192 or AES-256 keys by ID,
+// encrypting with the key current.
+func NewKeyring(current string, keys map[string][]byte) (*Keyring, error) {
+	if _, ok := keys[current]; !ok {
+		return nil, fmt.Errorf("%w: no key with current id %q", InvalidKeyError, current)
+	}
+
+	aeads := make(map[string]cipher.AEAD, len(keys))
+	for id, key := range keys {
+		if id == "" || len(id) > 255 {
+			return nil, fmt.Errorf("%w: key id %q must be 1 to 255 bytes", InvalidKeyError, id)
+		}
+		block, err := aes.NewCipher(key)
+		if err != nil {
+			return nil, fmt.Errorf("%w: key %q: %w", InvalidKeyError, id, err)
+		}
+		aead, err := cipher.NewGCM(block)
+		if err != nil {
+			return nil, err
+		}
+		aeads[id] = aead
+	}
+	return &Keyring{current: current, aeads: aeads}, nil
+}
+
+// encrypt seals b, stored under the backend key key, with the current key. The
+// result is encryptedFlag, the length and bytes of the key ID, the nonce, then
+// the ciphertext. The header up to the nonce and key are authenticated along
+// with b, so a value copied to another key does not decrypt.
+func (k *Keyring) encrypt(key string, b []byte) ([]byte, error) {
+	aead := k.aeads[k.current]
+
+	header := make([]byte, 0, 2+len(k.current))
+	header = append(header, encryptedFlag, byte(len(k.current)))
+	header = append(header, k.current...)
+
+	out := make([]byte, len(header)+aead.NonceSize(), len(header)+aead.NonceSize()+len(b)+aead.Overhead())
+	copy(out, header)
+	nonce := out[len(header):]
+	_, err := rand.Read(nonce)
+	if err != nil {
+		return nil, err
+	}
+	return aead.Seal(out, nonce, b, additionalData(header, key)), nil
+}
+
+// decrypt reverses encrypt with whichever key the value names. key must be the
+// backend key the value was read from.
+func (k *Keyring) decrypt(key string, b []byte) ([]byte, error) {
+	if len(b) < 2 || len(b) < 2+int(b[1]) {
+		return nil, CorruptDataError
+	}
+	headerLen := 2 + int(b[1])
+	id := string(b[2:headerLen])
+	aead, ok := k.aeads[id]
+	if !ok {
+		return nil, fmt.Errorf("%w: %q", UnknownKeyError, id)
+	}
+	if len(b) < headerLen+aead.NonceSize() {
+		return nil, CorruptDataError
+	}
+
+	nonce := b[headerLen : headerLen+aead.NonceSize()]
+	plaintext, err := aead.Open(nil, nonce, b[headerLen+aead.NonceSize():], additionalData(b[:headerLen], key))
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", CorruptDataError, err)
+	}
+	return plaintext, nil
+}
+
+// additionalData is the data authenticated along with a value: its header and
+// the backend key it is stored under.
+func additionalData(header []byte, key string) []byte {
+	data := make([]byte, 0, len(header)+len(key))
+	data = append(data, header...)
+	return append(data, key...)
+}
@@ -0,0 +1,184 @@
+package cache
+
+import (
+	"bytes"
+	"context"
+	"github.com/stretchr/testify/assert"
+	"strings"
+	"testing"
+)
+
+func newTestKeyring(t *testing.T, current string, ids ...string) *Keyring {
+	keys := make(map[string][]byte, len(ids))
+	for i, id := range ids {
+		keys[id] = bytes.Repeat([]byte{byte(i + 1)}, 32)
+	}
+	keyring, err := NewKeyring(current, keys)
+	assert.NoError(t, err)
+	return keyring
+}
+
+func TestCache_Encryption(t *testing.T) {
+	ctx := context.Background()
+	b := NewMemoryBackend()
+	name := strings.Repeat("secret ", 64)
+	c := New[testItem](b, WithEncryption(newTestKeyring(t, "k1", "k1")), WithCompression(128))
+
+	assert.NoError(t, c.WriteItem(ctx, "a", &testItem{Name: name}))
+	value, err := b.Get(ctx, "a")
+	assert.NoError(t, err)
+	assert.Equal(t, encryptedFlag, value[0])
+	assert.NotContains(t, string(value), "secret")
+
+	result, err := c.ReadItem(ctx, "a")
+	assert.NoError(t, err)
+	assert.Equal(t, &testItem{Name: name}, result.Data)
+
+	// After a rotation values written with the old key are still read.
+	rotated := New[testItem](b, WithEncryption(newTestKeyring(t, "k2", "k1", "k2")))
+	result, err = rotated.ReadItem(ctx, "a")
+	assert.NoError(t, err)
+	assert.Equal(t, &testItem{Name: name}, result.Data)
+
+	// Values that do not decode read as misses and are dropped.
+	assert.NoError(t, rotated.WriteItem(ctx, "b", &testItem{Name: "b"}))
+	result, err = c.ReadItem(ctx, "b")
+	assert.NoError(t, err)
+	assert.False(t, result.CacheHit, "the key of the value is unknown")
+	_, err = b.Get(ctx, "b")
+	assert.ErrorIs(t, err, MissError)
+
+	assert.NoError(t, rotated.WriteItem(ctx, "b", &testItem{Name: "b"}))
+	result, err = New[testItem](b).ReadItem(ctx, "b")
+	assert.NoError(t, err)
+	assert.False(t, result.CacheHit, "the cache has no keyring")
+
+	value[len(value)-1] ^= 0xff
+	assert.NoError(t, b.Set(ctx, "a", value, 0))
+	result, err = c.ReadItem(ctx, "a")
+	assert.NoError(t, err)
+	assert.False(t, result.CacheHit, "the value is corrupt")
+	_, err = b.Get(ctx, "a")
+	assert.ErrorIs(t, err, MissError)
+}
+
+func TestCache_FetchAfterKeyRemoved(t *testing.T) {
+	ctx := context.Background()
+	b := NewMemoryBackend()
+	loads := 0
+	loader := func(ctx context.Context, key string) (*testItem, error) {
+		loads++
+		return &testItem{Name: key}, nil
+	}
+
+	old := New[testItem](b, WithEncryption(newTestKeyring(t, "k1", "k1")))
+	_, err := old.Fetch(ctx, "a", loader)
+	assert.NoError(t, err)
+
+	// k1 is rotated out, and the value it encrypted is reloaded.
+	c := New[testItem](b, WithEncryption(newTestKeyring(t, "k2", "k2")))
+	result, err := c.Fetch(ctx, "a", loader)
+	assert.NoError(t, err)
+	assert.False(t, result.CacheHit)
+	assert.Equal(t, &testItem{Name: "a"}, result.Data)
+	assert.Equal(t, 2, loads)
+
+	result, err = c.Fetch(ctx, "a", loader)
+	assert.NoError(t, err)
+	assert.True(t, result.CacheHit)
+	assert.Equal(t, 2, loads)
+}
+
+func TestCache_EncryptionRejectsForeignValues(t *testing.T) {
+	ctx := context.Background()
+	keyring := newTestKeyring(t, "k1", "k1")
+
+	tests := []struct {
+		desc     string
+		opts     []CacheOpt
+		plant    func(t *testing.T, b Backend)
+		wantMiss bool
+	}{
+		{
+			desc: "a value copied from another key",
+			plant: func(t *testing.T, b Backend) {
+				c := New[testItem](b, WithEncryption(keyring))
+				assert.NoError(t, c.WriteItem(ctx, "b", &testItem{Name: "b"}))
+				value, err := b.Get(ctx, "b")
+				assert.NoError(t, err)
+				assert.NoError(t, b.Set(ctx, "a", value, 0))
+			},
+			wantMiss: true,
+		},
+		{
+			desc: "a value that is not encrypted",
+			plant: func(t *testing.T, b Backend) {
+				c := New[testItem](b)
+				assert.NoError(t, c.WriteItem(ctx, "a", &testItem{Name: "a"}))
+			},
+			wantMiss: true,
+		},
+		{
+			desc: "a value that is not encrypted, with plaintext reads",
+			opts: []CacheOpt{WithPlaintextReads()},
+			plant: func(t *testing.T, b Backend) {
+				c := New[testItem](b)
+				assert.NoError(t, c.WriteItem(ctx, "a", &testItem{Name: "a"}))
+			},
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.desc, func(t *testing.T) {
+			b := NewMemoryBackend()
+			tc.plant(t, b)
+
+			c := New[testItem](b, append([]CacheOpt{WithEncryption(keyring)}, tc.opts...)...)
+			result, err := c.ReadItem(ctx, "a")
+			assert.NoError(t, err)
+			if tc.wantMiss {
+				assert.False(t, result.CacheHit)
+				return
+			}
+			assert.Equal(t, &testItem{Name: "a"}, result.Data)
+		})
+	}
+}
+
+func TestNewKeyring(t *testing.T) {
+	tests := []struct {
+		desc    string
+		current string
+		keys    map[string][]byte
+		wantErr bool
+	}{
+		{
+			desc:    "happy path",
+			current: "k1",
+			keys:    map[string][]byte{"k1": make([]byte, 32), "k0": make([]byte, 16)},
+		},
+		{
+			desc:    "current key is missing",
+			current: "k2",
+			keys:    map[string][]byte{"k1": make([]byte, 32)},
+			wantErr: true,
+		},
+		{
+			desc:    "key has an invalid length",
+			current: "k1",
+			keys:    map[string][]byte{"k1": make([]byte, 10)},
+			wantErr: true,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.desc, func(t *testing.T) {
+			_, err := NewKeyring(tc.current, tc.keys)
+			if tc.wantErr {
+				assert.ErrorIs(t, err, InvalidKeyError)
+				return
+			}
+			assert.NoError(t, err)
+		})
+	}
+}
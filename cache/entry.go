@@ -0,0 +1,73 @@
+package cache
+
+import (
+	"math"
+	"math/rand/v2"
+	"time"
+)
+
+// entry is what a Cache stores in its backend: the value along with what is
+// needed to decide when to recompute it.
+type entry[T any] struct {
+	Value *T `json:"value"`
+	// Delta is how long the value took to compute, in milliseconds.
+	Delta int64 `json:"delta,omitempty"`
+	// Expiry is when the value expires, in unix milliseconds. Zero if it does
+	// not expire.
+	Expiry int64 `json:"expiry,omitempty"`
+	// Missing marks a tombstone for a key the source of truth does not have.
+	Missing bool `json:"missing,omitempty"`
+	// Tags holds the version of each tag the entry was written with.
+	Tags map[string]int64 `json:"tags,omitempty"`
+}
+
+func newEntry[T any](data *T, now time.Time, ttl time.Duration, delta time.Duration) *entry[T] {
+	e := &entry[T]{
+		Value: data,
+		Delta: delta.Milliseconds(),
+	}
+	if ttl > 0 {
+		e.Expiry = now.Add(ttl).UnixMilli()
+	}
+	return e
+}
+
+// stale reports whether the entry has expired by now.
+func (e *entry[T]) stale(now time.Time) bool {
+	return e.Expiry != 0 && now.UnixMilli() >= e.Expiry
+}
+
+func (e *entry[T]) tagNames() []string {
+	tags := make([]string, 0, len(e.Tags))
+	for tag := range e.Tags {
+		tags = append(tags, tag)
+	}
+	return tags
+}
+
+// current reports whether none of the entry's tags were invalidated since it
+// was written, given their current versions.
+func (e *entry[T]) current(versions map[string]int64) bool {
+	for tag, version := range e.Tags {
+		if versions[tag] != version {
+			return false
+		}
+	}
+	return true
+}
+
+// recomputeEarly implements XFetch (Vattani et al., "Optimal Probabilistic
+// Cache Stampede Prevention"). Each reader independently treats the entry as
+// expired with a probability that grows as the expiry approaches and with how
+// expensive the value is to compute, so a hot key tends to be recomputed by a
+// single reader shortly before it expires rather than by every reader after.
+// A larger beta recomputes earlier.
+func (e *entry[T]) recomputeEarly(now time.Time, beta float64) bool {
+	if beta <= 0 || e.Expiry == 0 || e.Delta <= 0 {
+		return false
+	}
+
+	// -ln(u) for u in (0, 1] is an exponentially distributed head start.
+	headStart := float64(e.Delta) * beta * -math.Log(1-rand.Float64())
+	return float64(now.UnixMilli())+headStart >= float64(e.Expiry)
+}
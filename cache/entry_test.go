@@ -0,0 +1,71 @@
+package cache
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+func Test_entryRecomputeEarly(t *testing.T) {
+	now := time.Now()
+	const reads = 1000
+
+	tests := []struct {
+		desc     string
+		entry    *entry[testItem]
+		beta     float64
+		readAt   time.Time
+		minEarly int
+		maxEarly int
+	}{
+		{
+			desc:     "far from expiry is never recomputed",
+			entry:    newEntry(&testItem{}, now, time.Hour, time.Second),
+			beta:     1,
+			readAt:   now,
+			maxEarly: 0,
+		},
+		{
+			desc:     "just before expiry is almost always recomputed",
+			entry:    newEntry(&testItem{}, now, time.Hour, time.Second),
+			beta:     1,
+			readAt:   now.Add(time.Hour - 10*time.Millisecond),
+			minEarly: reads * 95 / 100,
+			maxEarly: reads,
+		},
+		{
+			desc:     "about one compute cost before expiry is sometimes recomputed",
+			entry:    newEntry(&testItem{}, now, time.Hour, time.Second),
+			beta:     1,
+			readAt:   now.Add(time.Hour - time.Second),
+			minEarly: reads * 20 / 100,
+			maxEarly: reads * 50 / 100,
+		},
+		{
+			desc:     "disabled without beta",
+			entry:    newEntry(&testItem{}, now, time.Hour, time.Second),
+			readAt:   now.Add(time.Hour - time.Millisecond),
+			maxEarly: 0,
+		},
+		{
+			desc:     "entries without a compute cost are never recomputed",
+			entry:    newEntry(&testItem{}, now, time.Hour, 0),
+			beta:     1,
+			readAt:   now.Add(time.Hour - time.Millisecond),
+			maxEarly: 0,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.desc, func(t *testing.T) {
+			early := 0
+			for range reads {
+				if tc.entry.recomputeEarly(tc.readAt, tc.beta) {
+					early++
+				}
+			}
+			assert.GreaterOrEqual(t, early, tc.minEarly)
+			assert.LessOrEqual(t, early, tc.maxEarly)
+		})
+	}
+}
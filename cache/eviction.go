@@ -0,0 +1,235 @@
+package cache
+
+import (
+	"container/list"
+	"math/rand/v2"
+)
+
+// EvictionPolicy selects which entry a bounded MemoryBackend drops when it is
+// full.
+type EvictionPolicy int
+
+const (
+	// LRU evicts the entry that was read or written least recently.
+	LRU EvictionPolicy = iota
+	// LFU evicts the entry that was read or written the fewest times, breaking
+	// ties by recency.
+	LFU
+	// LIFO evicts the entry that was written most recently.
+	LIFO
+	// RandomReplacement evicts an entry picked at random.
+	RandomReplacement
+)
+
+func (p EvictionPolicy) String() string {
+	switch p {
+	case LRU:
+		return "lru"
+	case LFU:
+		return "lfu"
+	case LIFO:
+		return "lifo"
+	case RandomReplacement:
+		return "random"
+	default:
+		return "unknown"
+	}
+}
+
+// EvictionCallback is called with every entry dropped to make room.
+type EvictionCallback func(key string, value []byte)
+
+// evictor tracks the keys of a bounded backend in the order its policy evicts
+// them. Its methods are called with the backend's lock held.
+type evictor interface {
+	added(key string)
+	accessed(key string)
+	removed(key string)
+	// victim returns the key to evict next.
+	victim() (string, bool)
+}
+
+func newEvictor(policy EvictionPolicy) evictor {
+	switch policy {
+	case LFU:
+		return newLFUEvictor()
+	case LIFO:
+		return newListEvictor(false)
+	case RandomReplacement:
+		return newRandomEvictor()
+	default:
+		return newListEvictor(true)
+	}
+}
+
+// listEvictor keeps keys newest first. With moveOnAccess it is an LRU,
+// without it the front is the last key written and it is a LIFO.
+type listEvictor struct {
+	moveOnAccess bool
+	order        *list.List
+	elements     map[string]*list.Element
+}
+
+func newListEvictor(moveOnAccess bool) *listEvictor {
+	return &listEvictor{
+		moveOnAccess: moveOnAccess,
+		order:        list.New(),
+		elements:     make(map[string]*list.Element),
+	}
+}
+
+func (e *listEvictor) added(key string) {
+	e.elements[key] = e.order.PushFront(key)
+}
+
+func (e *listEvictor) accessed(key string) {
+	if !e.moveOnAccess {
+		return
+	}
+	if element, ok := e.elements[key]; ok {
+		e.order.MoveToFront(element)
+	}
+}
+
+func (e *listEvictor) removed(key string) {
+	if element, ok := e.elements[key]; ok {
+		e.order.Remove(element)
+		delete(e.elements, key)
+	}
+}
+
+func (e *listEvictor) victim() (string, bool) {
+	var element *list.Element
+	if e.moveOnAccess {
+		element = e.order.Back()
+	} else {
+		element = e.order.Front()
+	}
+	if element == nil {
+		return "", false
+	}
+	return element.Value.(string), true
+}
+
+type lfuNode struct {
+	key       string
+	frequency int
+}
+
+// lfuEvictor keeps one recency list per access frequency, so that accesses and
+// evictions are O(1).
+type lfuEvictor struct {
+	elements     map[string]*list.Element
+	frequencies  map[int]*list.List
+	minFrequency int
+}
+
+func newLFUEvictor() *lfuEvictor {
+	return &lfuEvictor{
+		elements:    make(map[string]*list.Element),
+		frequencies: make(map[int]*list.List),
+	}
+}
+
+func (e *lfuEvictor) push(node *lfuNode) {
+	keys, ok := e.frequencies[node.frequency]
+	if !ok {
+		keys = list.New()
+		e.frequencies[node.frequency] = keys
+	}
+	e.elements[node.key] = keys.PushFront(node)
+}
+
+// unlink removes element from its frequency list and returns its node.
+func (e *lfuEvictor) unlink(element *list.Element) *lfuNode {
+	node := element.Value.(*lfuNode)
+	keys := e.frequencies[node.frequency]
+	keys.Remove(element)
+	if keys.Len() == 0 {
+		delete(e.frequencies, node.frequency)
+	}
+	return node
+}
+
+func (e *lfuEvictor) added(key string) {
+	e.push(&lfuNode{key: key, frequency: 1})
+	e.minFrequency = 1
+}
+
+func (e *lfuEvictor) accessed(key string) {
+	element, ok := e.elements[key]
+	if !ok {
+		return
+	}
+	node := e.unlink(element)
+	if _, ok := e.frequencies[e.minFrequency]; !ok && node.frequency == e.minFrequency {
+		e.minFrequency++
+	}
+	node.frequency++
+	e.push(node)
+}
+
+func (e *lfuEvictor) removed(key string) {
+	element, ok := e.elements[key]
+	if !ok {
+		return
+	}
+	e.unlink(element)
+	delete(e.elements, key)
+}
+
+func (e *lfuEvictor) victim() (string, bool) {
+	if len(e.elements) == 0 {
+		return "", false
+	}
+
+	keys, ok := e.frequencies[e.minFrequency]
+	if !ok {
+		// The least frequent key was removed, find the new minimum.
+		e.minFrequency = 0
+		for frequency := range e.frequencies {
+			if e.minFrequency == 0 || frequency < e.minFrequency {
+				e.minFrequency = frequency
+			}
+		}
+		keys = e.frequencies[e.minFrequency]
+	}
+	return keys.Back().Value.(*lfuNode).key, true
+}
+
+type randomEvictor struct {
+	keys    []string
+	indexes map[string]int
+}
+
+func newRandomEvictor() *randomEvictor {
+	return &randomEvictor{
+		indexes: make(map[string]int),
+	}
+}
+
+func (e *randomEvictor) added(key string) {
+	e.indexes[key] = len(e.keys)
+	e.keys = append(e.keys, key)
+}
+
+func (e *randomEvictor) accessed(key string) {}
+
+func (e *randomEvictor) removed(key string) {
+	i, ok := e.indexes[key]
+	if !ok {
+		return
+	}
+	last := len(e.keys) - 1
+	e.keys[i] = e.keys[last]
+	e.indexes[e.keys[i]] = i
+	e.keys = e.keys[:last]
+	delete(e.indexes, key)
+}
+
+func (e *randomEvictor) victim() (string, bool) {
+	if len(e.keys) == 0 {
+		return "", false
+	}
+	return e.keys[rand.IntN(len(e.keys))], true
+}
@@ -0,0 +1,148 @@
+package cache
+
+import (
+	"context"
+	"fmt"
+	"github.com/stretchr/testify/assert"
+	"math/rand"
+	"testing"
+)
+
+func TestMemoryBackend_Eviction(t *testing.T) {
+	ctx := context.Background()
+
+	tests := []struct {
+		desc   string
+		policy EvictionPolicy
+		// reads are done after writing a, b and c and before writing d.
+		reads   []string
+		evicted string
+	}{
+		{
+			desc:    "lru evicts the least recently read key",
+			policy:  LRU,
+			reads:   []string{"a", "c"},
+			evicted: "b",
+		},
+		{
+			desc:    "lru evicts the oldest key when nothing is read",
+			policy:  LRU,
+			evicted: "a",
+		},
+		{
+			desc:    "lfu evicts the least frequently read key",
+			policy:  LFU,
+			reads:   []string{"a", "a", "b", "c", "c"},
+			evicted: "b",
+		},
+		{
+			desc:    "lfu breaks ties by recency",
+			policy:  LFU,
+			reads:   []string{"b", "a"},
+			evicted: "c",
+		},
+		{
+			desc:    "lifo evicts the last key written",
+			policy:  LIFO,
+			reads:   []string{"a", "b"},
+			evicted: "c",
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.desc, func(t *testing.T) {
+			var evicted []string
+			b := NewMemoryBackend(
+				WithEvictionPolicy(tc.policy),
+				WithMaxEntries(3),
+				WithEvictionCallback(func(key string, value []byte) {
+					evicted = append(evicted, key)
+				}),
+			)
+
+			for _, key := range []string{"a", "b", "c"} {
+				assert.NoError(t, b.Set(ctx, key, []byte(key), 0))
+			}
+			for _, key := range tc.reads {
+				_, err := b.Get(ctx, key)
+				assert.NoError(t, err)
+			}
+			assert.NoError(t, b.Set(ctx, "d", []byte("d"), 0))
+
+			assert.Equal(t, []string{tc.evicted}, evicted)
+			assert.Equal(t, 3, b.Len())
+			_, err := b.Get(ctx, tc.evicted)
+			assert.ErrorIs(t, err, MissError)
+			_, err = b.Get(ctx, "d")
+			assert.NoError(t, err)
+		})
+	}
+}
+
+func TestMemoryBackend_RandomReplacement(t *testing.T) {
+	ctx := context.Background()
+	b := NewMemoryBackend(WithEvictionPolicy(RandomReplacement), WithMaxEntries(10))
+
+	for i := range 100 {
+		assert.NoError(t, b.Set(ctx, fmt.Sprint(i), []byte("v"), 0))
+		assert.LessOrEqual(t, b.Len(), 10)
+	}
+	_, err := b.Get(ctx, "99")
+	assert.NoError(t, err)
+}
+
+func TestMemoryBackend_MaxBytes(t *testing.T) {
+	ctx := context.Background()
+	evictions := 0
+	b := NewMemoryBackend(
+		WithMaxBytes(100),
+		WithEvictionCallback(func(key string, value []byte) {
+			evictions++
+		}),
+	)
+
+	// Each entry is a 1 byte key and a 29 byte value.
+	for _, key := range []string{"a", "b", "c", "d"} {
+		assert.NoError(t, b.Set(ctx, key, make([]byte, 29), 0))
+		assert.LessOrEqual(t, b.Bytes(), int64(100))
+	}
+	assert.Equal(t, 1, evictions)
+	assert.Equal(t, int64(90), b.Bytes())
+
+	// Overwriting a key replaces its size rather than adding to it.
+	assert.NoError(t, b.Set(ctx, "d", make([]byte, 9), 0))
+	assert.Equal(t, int64(70), b.Bytes())
+	assert.Equal(t, 1, evictions)
+}
+
+// BenchmarkEvictionPolicies replays a skewed, todo like access pattern where a
+// few todos are read far more often than the rest, and reports each policy's
+// hit ratio.
+func BenchmarkEvictionPolicies(b *testing.B) {
+	ctx := context.Background()
+	const (
+		todos    = 10_000
+		capacity = 500
+	)
+
+	for _, policy := range []EvictionPolicy{LRU, LFU, LIFO, RandomReplacement} {
+		b.Run(policy.String(), func(b *testing.B) {
+			backend := NewMemoryBackend(WithEvictionPolicy(policy), WithMaxEntries(capacity))
+			zipf := rand.NewZipf(rand.New(rand.NewSource(1)), 1.1, 1, todos-1)
+			value := make([]byte, 256)
+
+			hits := 0
+			b.ResetTimer()
+			for range b.N {
+				key := fmt.Sprint(zipf.Uint64())
+				_, err := backend.Get(ctx, key)
+				if err == nil {
+					hits++
+					continue
+				}
+				_ = backend.Set(ctx, key, value, 0)
+			}
+			b.ReportMetric(float64(hits)/float64(b.N), "hit-ratio")
+		})
+	}
+}
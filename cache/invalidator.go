@@ -0,0 +1,206 @@
+package cache
+
+import (
+	"context"
+	"encoding/json"
+	"github.com/go-redis/redis/v8"
+	"github.com/google/uuid"
+	"log/slog"
+	"time"
+)
+
+// invalidatorRetryDelay is how long Run waits after a failed receive before
+// reading from the subscription again.
+const invalidatorRetryDelay = time.Second
+
+type invalidationMessage struct {
+	// Source is the ID of the instance that changed the keys.
+	Source string   `json:"source"`
+	Keys   []string `json:"keys"`
+}
+
+// Invalidator keeps the in-process tier of every instance consistent. Writes
+// and deletes made through a backend returned by Wrap are published on a Redis
+// channel, and Run evicts the keys published by other instances from the
+// local tier.
+//
+// Messages published while an instance is disconnected are lost, so the local
+// tier is cleared every time the subscription is (re)established.
+type Invalidator struct {
+	redisClient *redis.Client
+	channel     string
+	id          string
+	local       *MemoryBackend
+}
+
+func NewInvalidator(redisClient *redis.Client, channel string, local *MemoryBackend) *Invalidator {
+	return &Invalidator{
+		redisClient: redisClient,
+		channel:     channel,
+		id:          uuid.New().String(),
+		local:       local,
+	}
+}
+
+// Wrap returns backend with every Set and Delete published to other
+// instances.
+func (i *Invalidator) Wrap(backend Backend) Backend {
+	return &publishingBackend{
+		Backend:     backend,
+		invalidator: i,
+	}
+}
+
+// Publish tells other instances to evict keys from their local tier.
+func (i *Invalidator) Publish(ctx context.Context, keys ...string) error {
+	if len(keys) == 0 {
+		return nil
+	}
+
+	b, err := json.Marshal(invalidationMessage{
+		Source: i.id,
+		Keys:   keys,
+	})
+	if err != nil {
+		return err
+	}
+	return i.redisClient.Publish(ctx, i.channel, b).Err()
+}
+
+// Run subscribes to the invalidation channel and applies messages until ctx
+// is done.
+func (i *Invalidator) Run(ctx context.Context) error {
+	pubsub := i.redisClient.Subscribe(ctx, i.channel)
+	defer pubsub.Close()
+
+	for {
+		msg, err := pubsub.Receive(ctx)
+		if err != nil {
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+			// The subscription is re-established by the next Receive.
+			slog.Error("invalidator receive", slog.Any("error", err))
+			select {
+			case <-time.After(invalidatorRetryDelay):
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+			continue
+		}
+
+		switch msg := msg.(type) {
+		case *redis.Subscription:
+			if msg.Kind == "subscribe" {
+				i.resync()
+			}
+		case *redis.Message:
+			i.handle(ctx, msg.Payload)
+		}
+	}
+}
+
+// resync clears the local tier, as invalidations may have been missed while
+// the instance was not subscribed.
+func (i *Invalidator) resync() {
+	slog.Info("invalidator subscribed, clearing local cache",
+		slog.String("channel", i.channel),
+	)
+	i.local.Clear()
+}
+
+func (i *Invalidator) handle(ctx context.Context, payload string) {
+	var msg invalidationMessage
+	err := json.Unmarshal([]byte(payload), &msg)
+	if err != nil {
+		slog.Error("invalidator message", slog.Any("error", err))
+		return
+	}
+
+	// The local tier of the publisher was updated by the write itself.
+	if msg.Source == i.id {
+		return
+	}
+
+	err = i.local.Delete(ctx, msg.Keys...)
+	if err != nil {
+		slog.Error("invalidator delete", slog.Any("error", err))
+	}
+}
+
+// publishingBackend publishes the keys of every Set, Delete and Incr once the
+// wrapped backend has applied them.
+type publishingBackend struct {
+	Backend
+	invalidator *Invalidator
+}
+
+func (b *publishingBackend) Set(ctx context.Context, key string, value []byte, ttl time.Duration) error {
+	err := b.Backend.Set(ctx, key, value, ttl)
+	if err != nil {
+		return err
+	}
+	return b.invalidator.Publish(ctx, key)
+}
+
+func (b *publishingBackend) MSet(ctx context.Context, items ...KeyValue) error {
+	err := b.Backend.MSet(ctx, items...)
+	if err != nil {
+		return err
+	}
+
+	keys := make([]string, len(items))
+	for i, item := range items {
+		keys[i] = item.Key
+	}
+	return b.invalidator.Publish(ctx, keys...)
+}
+
+func (b *publishingBackend) Delete(ctx context.Context, keys ...string) error {
+	err := b.Backend.Delete(ctx, keys...)
+	if err != nil {
+		return err
+	}
+	return b.invalidator.Publish(ctx, keys...)
+}
+
+func (b *publishingBackend) Incr(ctx context.Context, key string) (int64, error) {
+	n, err := b.Backend.Incr(ctx, key)
+	if err != nil {
+		return 0, err
+	}
+	return n, b.invalidator.Publish(ctx, key)
+}
+
+func (b *publishingBackend) SetLeased(
+	ctx context.Context,
+	key string,
+	leaseKey string,
+	token string,
+	value []byte,
+	ttl time.Duration,
+) (bool, error) {
+	set, err := b.Backend.SetLeased(ctx, key, leaseKey, token, value, ttl)
+	if err != nil || !set {
+		return set, err
+	}
+	return true, b.invalidator.Publish(ctx, key)
+}
+
+func (b *publishingBackend) MSetLeased(ctx context.Context, items ...LeasedKeyValue) ([]bool, error) {
+	set, err := b.Backend.MSetLeased(ctx, items...)
+	if err != nil {
+		return nil, err
+	}
+
+	var keys []string
+	for i, item := range items {
+		if set[i] {
+			keys = append(keys, item.Key)
+		}
+	}
+	if len(keys) == 0 {
+		return set, nil
+	}
+	return set, b.invalidator.Publish(ctx, keys...)
+}
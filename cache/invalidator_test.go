@@ -0,0 +1,62 @@
+package cache
+
+import (
+	"context"
+	"encoding/json"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestInvalidator_handle(t *testing.T) {
+	ctx := context.Background()
+
+	tests := []struct {
+		desc      string
+		source    string
+		remaining int
+	}{
+		{
+			desc:      "message from another instance evicts the keys",
+			source:    "other",
+			remaining: 1,
+		},
+		{
+			desc:      "message from this instance is ignored",
+			remaining: 3,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.desc, func(t *testing.T) {
+			local := NewMemoryBackend()
+			i := NewInvalidator(nil, "invalidations", local)
+			for _, key := range []string{"a", "b", "c"} {
+				assert.NoError(t, local.Set(ctx, key, []byte(key), 0))
+			}
+
+			source := tc.source
+			if source == "" {
+				source = i.id
+			}
+			payload, err := json.Marshal(invalidationMessage{
+				Source: source,
+				Keys:   []string{"a", "b"},
+			})
+			assert.NoError(t, err)
+
+			i.handle(ctx, string(payload))
+			assert.Equal(t, tc.remaining, local.Len())
+		})
+	}
+}
+
+func TestInvalidator_resync(t *testing.T) {
+	ctx := context.Background()
+	local := NewMemoryBackend()
+	i := NewInvalidator(nil, "invalidations", local)
+	assert.NoError(t, local.Set(ctx, "a", []byte("a"), 0))
+
+	i.resync()
+	assert.Equal(t, 0, local.Len())
+	assert.Equal(t, int64(0), local.Bytes())
+}
@@ -0,0 +1,103 @@
+package cache
+
+import (
+	"context"
+	"github.com/go-redis/redis/v8"
+	"maps"
+	"sync"
+)
+
+// Journal records the dirty keys of a WriteBehind and their latest encoded
+// value. It lets queued writes survive a process restart, independently of
+// whether the cached entry itself has been evicted.
+//
+// A journal belongs to a single process. Start replays everything in it, so a
+// journal shared by live processes would flush writes another process still
+// holds, possibly over newer ones.
+type Journal interface {
+	Record(ctx context.Context, key string, value []byte) error
+	Remove(ctx context.Context, keys ...string) error
+	// Load returns every key that has not been flushed yet.
+	Load(ctx context.Context) (map[string][]byte, error)
+}
+
+var (
+	_ Journal = (*RedisJournal)(nil)
+	_ Journal = (*MemoryJournal)(nil)
+)
+
+// RedisJournal keeps a journal in a Redis hash.
+type RedisJournal struct {
+	redisClient *redis.Client
+	name        string
+}
+
+// NewRedisJournal returns the journal name of the process instance. instance
+// must be unique among the live processes and stable across restarts of the
+// same one, such as a StatefulSet pod name, so that a restarted process
+// replays its own writes and no one else's.
+func NewRedisJournal(redisClient *redis.Client, name string, instance string) *RedisJournal {
+	return &RedisJournal{
+		redisClient: redisClient,
+		name:        "journal:" + name + ":" + instance,
+	}
+}
+
+func (j *RedisJournal) Record(ctx context.Context, key string, value []byte) error {
+	return j.redisClient.HSet(ctx, j.name, key, value).Err()
+}
+
+func (j *RedisJournal) Remove(ctx context.Context, keys ...string) error {
+	if len(keys) == 0 {
+		return nil
+	}
+	return j.redisClient.HDel(ctx, j.name, keys...).Err()
+}
+
+func (j *RedisJournal) Load(ctx context.Context) (map[string][]byte, error) {
+	entries, err := j.redisClient.HGetAll(ctx, j.name).Result()
+	if err != nil {
+		return nil, err
+	}
+
+	values := make(map[string][]byte, len(entries))
+	for key, value := range entries {
+		values[key] = []byte(value)
+	}
+	return values, nil
+}
+
+// MemoryJournal keeps a journal in process memory. It does not survive the
+// process, so it only suits tests and setups that can lose queued writes.
+type MemoryJournal struct {
+	mu      sync.Mutex
+	entries map[string][]byte
+}
+
+func NewMemoryJournal() *MemoryJournal {
+	return &MemoryJournal{
+		entries: make(map[string][]byte),
+	}
+}
+
+func (j *MemoryJournal) Record(ctx context.Context, key string, value []byte) error {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	j.entries[key] = value
+	return nil
+}
+
+func (j *MemoryJournal) Remove(ctx context.Context, keys ...string) error {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	for _, key := range keys {
+		delete(j.entries, key)
+	}
+	return nil
+}
+
+func (j *MemoryJournal) Load(ctx context.Context) (map[string][]byte, error) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	return maps.Clone(j.entries), nil
+}
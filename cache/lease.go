@@ -0,0 +1,212 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"github.com/google/uuid"
+	"log/slog"
+	"time"
+)
+
+var LeaseLostError = errors.New("cache lease is no longer valid")
+
+// Lease is handed out with a miss when the cache is built WithLeases. Only the
+// holder of a granted lease may fill the key, and the fill is rejected if the
+// key was invalidated or written since the lease was granted. This stops a slow
+// reader from writing back a value that an update has already invalidated.
+//
+// At most one lease per key is granted at a time. Other readers missing the
+// key get a lease that is not granted, and should read the source of truth
+// without filling the cache.
+type Lease struct {
+	token string
+	// versions are the versions of the WithLeaseTags tags when the lease was
+	// granted.
+	versions map[string]int64
+}
+
+// Granted reports whether the lease allows filling the key.
+func (l *Lease) Granted() bool {
+	return l != nil && l.token != ""
+}
+
+// tagVersions returns the versions the lease recorded for tags, and the tags
+// it has no version for.
+func (l *Lease) tagVersions(tags []string) (map[string]int64, []string) {
+	if l == nil || len(l.versions) == 0 {
+		return nil, tags
+	}
+	versions := make(map[string]int64, len(tags))
+	var unknown []string
+	for _, tag := range tags {
+		version, ok := l.versions[tag]
+		if !ok {
+			unknown = append(unknown, tag)
+			continue
+		}
+		versions[tag] = version
+	}
+	return versions, unknown
+}
+
+func leaseKey(key string) string {
+	return "lease:" + key
+}
+
+// AcquireLease requests a lease to fill key. It returns nil when leases are
+// disabled.
+func (c *Cache[T]) AcquireLease(ctx context.Context, key string, opts ...ReadOpt) *Lease {
+	if c.opts.leaseTTL <= 0 {
+		return nil
+	}
+
+	token := uuid.New().String()
+	granted, err := c.backend.Lease(ctx, leaseKey(c.key(key)), token, c.opts.leaseTTL)
+	if err != nil {
+		// Without a lease the caller does not fill, which is always safe.
+		slog.Error("cache lease",
+			slog.Any("error", err),
+			slog.String("key", key),
+		)
+		return &Lease{}
+	}
+	if !granted {
+		return &Lease{}
+	}
+	leases := map[string]*Lease{key: {token: token}}
+	c.recordTagVersions(ctx, leases, opts)
+	return leases[key]
+}
+
+// AcquireLeases requests a lease to fill each of keys in one call to the
+// backend. It returns nil when leases are disabled.
+func (c *Cache[T]) AcquireLeases(ctx context.Context, keys []string, opts ...ReadOpt) map[string]*Lease {
+	if c.opts.leaseTTL <= 0 {
+		return nil
+	}
+
+	requests := make([]KeyValue, len(keys))
+	for i, key := range keys {
+		requests[i] = KeyValue{
+			Key:   leaseKey(c.key(key)),
+			Value: []byte(uuid.New().String()),
+			TTL:   c.opts.leaseTTL,
+		}
+	}
+	granted, err := c.backend.MLease(ctx, requests...)
+	if err != nil {
+		slog.Error("cache leases",
+			slog.Any("error", err),
+			slog.Int("keys", len(keys)),
+		)
+		granted = make([]bool, len(keys))
+	}
+
+	leases := make(map[string]*Lease, len(keys))
+	for i, key := range keys {
+		if !granted[i] {
+			leases[key] = &Lease{}
+			continue
+		}
+		leases[key] = &Lease{token: string(requests[i].Value)}
+	}
+
+	c.recordTagVersions(ctx, leases, opts)
+	return leases
+}
+
+// recordTagVersions records the versions of the WithLeaseTags tags on the
+// granted leases. They are read after the leases are granted and before the
+// caller loads the values, so any InvalidateTag they miss happens after the
+// load started, and drops the fill. If they cannot be read the leases are
+// given up.
+func (c *Cache[T]) recordTagVersions(ctx context.Context, leases map[string]*Lease, opts []ReadOpt) {
+	o := newReadOptions(opts)
+	if len(o.leaseTags) == 0 {
+		return
+	}
+	granted := make(map[string]*Lease, len(leases))
+	for key, lease := range leases {
+		if lease.Granted() {
+			granted[key] = lease
+		}
+	}
+	if len(granted) == 0 {
+		return
+	}
+
+	versions, err := c.tagVersions(ctx, o.leaseTags)
+	if err != nil {
+		slog.Error("cache lease tag versions",
+			slog.Any("error", err),
+			slog.Int("keys", len(granted)),
+		)
+		err = c.ReleaseLeases(ctx, granted)
+		if err != nil {
+			slog.Error("cache lease release",
+				slog.Any("error", err),
+				slog.Int("keys", len(granted)),
+			)
+		}
+		for key := range granted {
+			leases[key] = &Lease{}
+		}
+		return
+	}
+	for _, lease := range granted {
+		lease.versions = versions
+	}
+}
+
+// ReleaseLease gives up lease without filling key, so that the next reader
+// missing the key can be granted one. It does nothing unless lease is granted.
+// Releasing a lease that has expired may cancel a later reader's lease, which
+// only makes that reader skip its fill.
+func (c *Cache[T]) ReleaseLease(ctx context.Context, key string, lease *Lease) error {
+	if !lease.Granted() {
+		return nil
+	}
+	return c.backend.Delete(ctx, leaseKey(c.key(key)))
+}
+
+// ReleaseLeases is ReleaseLease for several keys, in one call to the backend.
+func (c *Cache[T]) ReleaseLeases(ctx context.Context, leases map[string]*Lease) error {
+	keys := make([]string, 0, len(leases))
+	for key, lease := range leases {
+		if lease.Granted() {
+			keys = append(keys, leaseKey(c.key(key)))
+		}
+	}
+	if len(keys) == 0 {
+		return nil
+	}
+	return c.backend.Delete(ctx, keys...)
+}
+
+// set stores an encoded entry. With a lease the write only succeeds while the
+// lease is valid. Without one, any outstanding lease is cancelled first so that
+// a fill started before this write cannot overwrite it.
+func (c *Cache[T]) set(ctx context.Context, key string, b []byte, ttl time.Duration, lease *Lease) error {
+	key = c.key(key)
+	if lease != nil {
+		if !lease.Granted() {
+			return LeaseLostError
+		}
+		set, err := c.backend.SetLeased(ctx, key, leaseKey(key), lease.token, b, ttl)
+		if err != nil {
+			return err
+		}
+		if !set {
+			return LeaseLostError
+		}
+		return nil
+	}
+
+	if c.opts.leaseTTL > 0 {
+		err := c.backend.Delete(ctx, leaseKey(key))
+		if err != nil {
+			return err
+		}
+	}
+	return c.backend.Set(ctx, key, b, ttl)
+}
@@ -0,0 +1,247 @@
+package cache
+
+import (
+	"context"
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+func TestCache_Leases(t *testing.T) {
+	ctx := context.Background()
+
+	tests := []struct {
+		desc string
+		run  func(t *testing.T, c *Cache[testItem])
+	}{
+		{
+			desc: "happy path: miss then fill under the lease",
+			run: func(t *testing.T, c *Cache[testItem]) {
+				result, err := c.ReadItem(ctx, "a")
+				assert.NoError(t, err)
+				assert.True(t, result.Lease.Granted())
+
+				err = c.WriteItem(ctx, "a", &testItem{Name: "a"}, WithLease(result.Lease))
+				assert.NoError(t, err)
+
+				result, err = c.ReadItem(ctx, "a")
+				assert.NoError(t, err)
+				assert.True(t, result.CacheHit)
+				assert.Nil(t, result.Lease)
+			},
+		},
+		{
+			desc: "invalidation cancels the lease of a slow fill",
+			run: func(t *testing.T, c *Cache[testItem]) {
+				result, err := c.ReadItem(ctx, "a")
+				assert.NoError(t, err)
+
+				assert.NoError(t, c.InvalidateKey(ctx, "a"))
+
+				err = c.WriteItem(ctx, "a", &testItem{Name: "stale"}, WithLease(result.Lease))
+				assert.ErrorIs(t, err, LeaseLostError)
+
+				result, err = c.ReadItem(ctx, "a")
+				assert.NoError(t, err)
+				assert.False(t, result.CacheHit)
+			},
+		},
+		{
+			desc: "a write cancels the lease of a slow fill",
+			run: func(t *testing.T, c *Cache[testItem]) {
+				result, err := c.ReadItem(ctx, "a")
+				assert.NoError(t, err)
+
+				assert.NoError(t, c.WriteItem(ctx, "a", &testItem{Name: "new"}))
+
+				err = c.WriteItem(ctx, "a", &testItem{Name: "stale"}, WithLease(result.Lease))
+				assert.ErrorIs(t, err, LeaseLostError)
+
+				result, err = c.ReadItem(ctx, "a")
+				assert.NoError(t, err)
+				assert.Equal(t, &testItem{Name: "new"}, result.Data)
+			},
+		},
+		{
+			desc: "a released lease can be granted again",
+			run: func(t *testing.T, c *Cache[testItem]) {
+				first, err := c.ReadItem(ctx, "a")
+				assert.NoError(t, err)
+				assert.NoError(t, c.ReleaseLease(ctx, "a", first.Lease))
+
+				second, err := c.ReadItem(ctx, "a")
+				assert.NoError(t, err)
+				assert.True(t, second.Lease.Granted())
+
+				err = c.WriteItem(ctx, "a", &testItem{Name: "a"}, WithLease(second.Lease))
+				assert.NoError(t, err)
+			},
+		},
+		{
+			desc: "leases for several keys are granted per key",
+			run: func(t *testing.T, c *Cache[testItem]) {
+				held, err := c.ReadItem(ctx, "a")
+				assert.NoError(t, err)
+				assert.True(t, held.Lease.Granted())
+
+				leases := c.AcquireLeases(ctx, []string{"a", "b"})
+				assert.False(t, leases["a"].Granted())
+				assert.True(t, leases["b"].Granted())
+			},
+		},
+		{
+			desc: "only one lease is granted at a time",
+			run: func(t *testing.T, c *Cache[testItem]) {
+				first, err := c.ReadItem(ctx, "a")
+				assert.NoError(t, err)
+				second, err := c.ReadItem(ctx, "a")
+				assert.NoError(t, err)
+
+				assert.True(t, first.Lease.Granted())
+				assert.False(t, second.Lease.Granted())
+
+				err = c.WriteItem(ctx, "a", &testItem{Name: "a"}, WithLease(second.Lease))
+				assert.ErrorIs(t, err, LeaseLostError)
+			},
+		},
+		{
+			desc: "tombstones are filled under the lease",
+			run: func(t *testing.T, c *Cache[testItem]) {
+				result, err := c.ReadItem(ctx, "a")
+				assert.NoError(t, err)
+				assert.NoError(t, c.InvalidateKey(ctx, "a"))
+
+				err = c.WriteMissing(ctx, "a", WithLease(result.Lease))
+				assert.ErrorIs(t, err, LeaseLostError)
+			},
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.desc, func(t *testing.T) {
+			c := New[testItem](
+				NewMemoryBackend(),
+				WithLeases(time.Minute),
+				WithNegativeTTL(time.Minute),
+			)
+			tc.run(t, c)
+		})
+	}
+}
+
+// countingBackend counts the lease and tag calls made to the wrapped backend.
+type countingBackend struct {
+	Backend
+	calls map[string]int
+}
+
+func (b *countingBackend) MGet(ctx context.Context, keys ...string) ([][]byte, error) {
+	b.calls["MGet"]++
+	return b.Backend.MGet(ctx, keys...)
+}
+
+func (b *countingBackend) Lease(ctx context.Context, leaseKey string, token string, ttl time.Duration) (bool, error) {
+	b.calls["Lease"]++
+	return b.Backend.Lease(ctx, leaseKey, token, ttl)
+}
+
+func (b *countingBackend) SetLeased(
+	ctx context.Context,
+	key string,
+	leaseKey string,
+	token string,
+	value []byte,
+	ttl time.Duration,
+) (bool, error) {
+	b.calls["SetLeased"]++
+	return b.Backend.SetLeased(ctx, key, leaseKey, token, value, ttl)
+}
+
+func (b *countingBackend) MLease(ctx context.Context, leases ...KeyValue) ([]bool, error) {
+	b.calls["MLease"]++
+	return b.Backend.MLease(ctx, leases...)
+}
+
+func (b *countingBackend) MSetLeased(ctx context.Context, items ...LeasedKeyValue) ([]bool, error) {
+	b.calls["MSetLeased"]++
+	return b.Backend.MSetLeased(ctx, items...)
+}
+
+func TestCache_FillItems(t *testing.T) {
+	ctx := context.Background()
+	backend := &countingBackend{Backend: NewMemoryBackend(), calls: make(map[string]int)}
+	c := New[testItem](backend, WithLeases(time.Minute))
+
+	held := c.AcquireLease(ctx, "c")
+	leases := c.AcquireLeases(ctx, []string{"a", "b", "c"})
+	assert.True(t, leases["a"].Granted())
+	assert.True(t, leases["b"].Granted())
+	assert.False(t, leases["c"].Granted())
+	assert.NoError(t, c.InvalidateKey(ctx, "b"))
+
+	err := c.FillItems(ctx, map[string]*testItem{
+		"a": {Name: "a"},
+		"b": {Name: "stale"},
+		"c": {Name: "c"},
+	}, leases, WithTags("t"))
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]int{"Lease": 1, "MLease": 1, "MGet": 1, "MSetLeased": 1}, backend.calls)
+
+	results, err := c.ReadItems(ctx, "a", "b", "c")
+	assert.NoError(t, err)
+	assert.Equal(t, &testItem{Name: "a"}, results["a"].Data)
+	assert.False(t, results["b"].CacheHit, "a fill whose lease was lost is skipped")
+	assert.False(t, results["c"].CacheHit, "a fill without a granted lease is skipped")
+
+	assert.NoError(t, c.ReleaseLease(ctx, "c", held))
+	assert.NoError(t, c.ReleaseLeases(ctx, leases))
+	assert.True(t, c.AcquireLease(ctx, "c").Granted())
+}
+
+func TestCache_LeaseReleasedWithoutFill(t *testing.T) {
+	ctx := context.Background()
+
+	tests := []struct {
+		desc string
+		miss func(t *testing.T, c *Cache[testItem])
+	}{
+		{
+			desc: "no tombstone is written without a negative TTL",
+			miss: func(t *testing.T, c *Cache[testItem]) {
+				result, err := c.ReadItem(ctx, "a")
+				assert.NoError(t, err)
+				assert.NoError(t, c.WriteMissing(ctx, "a", WithLease(result.Lease)))
+			},
+		},
+		{
+			desc: "a failed load",
+			miss: func(t *testing.T, c *Cache[testItem]) {
+				_, err := c.Fetch(ctx, "a", func(ctx context.Context, key string) (*testItem, error) {
+					return nil, errBackendDown
+				})
+				assert.ErrorIs(t, err, errBackendDown)
+			},
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.desc, func(t *testing.T) {
+			c := New[testItem](NewMemoryBackend(), WithLeases(time.Minute))
+			tc.miss(t, c)
+
+			result, err := c.ReadItem(ctx, "a")
+			assert.NoError(t, err)
+			assert.True(t, result.Lease.Granted())
+		})
+	}
+}
+
+func TestCache_LeasesDisabled(t *testing.T) {
+	ctx := context.Background()
+	c := New[testItem](NewMemoryBackend())
+
+	result, err := c.ReadItem(ctx, "a")
+	assert.NoError(t, err)
+	assert.Nil(t, result.Lease)
+	assert.NoError(t, c.WriteItem(ctx, "a", &testItem{Name: "a"}, WithLease(result.Lease)))
+}
@@ -0,0 +1,330 @@
+package cache
+
+import (
+	"context"
+	"strconv"
+	"sync"
+	"time"
+)
+
+var _ Backend = (*MemoryBackend)(nil)
+
+type memoryEntry struct {
+	value []byte
+	// expiresAt is zero for entries that do not expire.
+	expiresAt time.Time
+}
+
+func (e *memoryEntry) expired(now time.Time) bool {
+	return !e.expiresAt.IsZero() && !now.Before(e.expiresAt)
+}
+
+func entrySize(key string, value []byte) int64 {
+	return int64(len(key) + len(value))
+}
+
+type memoryOptions struct {
+	policy     EvictionPolicy
+	maxEntries int
+	maxBytes   int64
+	onEvict    EvictionCallback
+}
+
+type MemoryOpt func(o *memoryOptions)
+
+// WithEvictionPolicy selects the entry dropped when the backend is full. The
+// default is LRU.
+func WithEvictionPolicy(policy EvictionPolicy) MemoryOpt {
+	return func(o *memoryOptions) {
+		o.policy = policy
+	}
+}
+
+// WithMaxEntries bounds how many entries the backend holds.
+func WithMaxEntries(maxEntries int) MemoryOpt {
+	return func(o *memoryOptions) {
+		o.maxEntries = maxEntries
+	}
+}
+
+// WithMaxBytes bounds the total size of the keys and values the backend holds.
+func WithMaxBytes(maxBytes int64) MemoryOpt {
+	return func(o *memoryOptions) {
+		o.maxBytes = maxBytes
+	}
+}
+
+// WithEvictionCallback is called with every entry evicted to make room. It runs
+// with the backend locked and must not call back into it.
+func WithEvictionCallback(onEvict EvictionCallback) MemoryOpt {
+	return func(o *memoryOptions) {
+		o.onEvict = onEvict
+	}
+}
+
+// MemoryBackend keeps entries in process memory. It lets a Cache run without
+// Redis, for single node deployments and for tests. Expired entries are
+// removed when they are next accessed.
+//
+// Without WithMaxEntries or WithMaxBytes the backend is unbounded. Otherwise
+// entries are evicted by its EvictionPolicy once a write would exceed either
+// limit.
+type MemoryBackend struct {
+	mu      sync.Mutex
+	entries map[string]*memoryEntry
+	bytes   int64
+	evictor evictor
+	opts    memoryOptions
+	now     func() time.Time
+}
+
+func NewMemoryBackend(opts ...MemoryOpt) *MemoryBackend {
+	var o memoryOptions
+	for _, opt := range opts {
+		opt(&o)
+	}
+
+	return &MemoryBackend{
+		entries: make(map[string]*memoryEntry),
+		evictor: newEvictor(o.policy),
+		opts:    o,
+		now:     time.Now,
+	}
+}
+
+func (b *MemoryBackend) Get(ctx context.Context, key string) ([]byte, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	entry, ok := b.lookup(key)
+	if !ok {
+		return nil, MissError
+	}
+	return entry.value, nil
+}
+
+func (b *MemoryBackend) MGet(ctx context.Context, keys ...string) ([][]byte, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	values := make([][]byte, len(keys))
+	for i, key := range keys {
+		if entry, ok := b.lookup(key); ok {
+			values[i] = entry.value
+		}
+	}
+	return values, nil
+}
+
+func (b *MemoryBackend) Set(ctx context.Context, key string, value []byte, ttl time.Duration) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	// Copy so that callers reusing their buffer cannot change stored entries.
+	stored := make([]byte, len(value))
+	copy(stored, value)
+	b.store(key, &memoryEntry{
+		value:     stored,
+		expiresAt: b.expiresAt(ttl),
+	})
+	return nil
+}
+
+func (b *MemoryBackend) MSet(ctx context.Context, items ...KeyValue) error {
+	for _, item := range items {
+		err := b.Set(ctx, item.Key, item.Value, item.TTL)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (b *MemoryBackend) Delete(ctx context.Context, keys ...string) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	for _, key := range keys {
+		b.remove(key)
+	}
+	return nil
+}
+
+func (b *MemoryBackend) Expire(ctx context.Context, key string, ttl time.Duration) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if entry, ok := b.lookup(key); ok {
+		entry.expiresAt = b.expiresAt(ttl)
+	}
+	return nil
+}
+
+func (b *MemoryBackend) Incr(ctx context.Context, key string) (int64, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	var n int64
+	entry, ok := b.lookup(key)
+	if ok {
+		var err error
+		n, err = strconv.ParseInt(string(entry.value), 10, 64)
+		if err != nil {
+			return 0, err
+		}
+	} else {
+		entry = &memoryEntry{}
+	}
+
+	n++
+	b.store(key, &memoryEntry{
+		value:     []byte(strconv.FormatInt(n, 10)),
+		expiresAt: entry.expiresAt,
+	})
+	return n, nil
+}
+
+func (b *MemoryBackend) Lease(ctx context.Context, leaseKey string, token string, ttl time.Duration) (bool, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if _, ok := b.lookup(leaseKey); ok {
+		return false, nil
+	}
+	b.store(leaseKey, &memoryEntry{
+		value:     []byte(token),
+		expiresAt: b.expiresAt(ttl),
+	})
+	return true, nil
+}
+
+func (b *MemoryBackend) SetLeased(
+	ctx context.Context,
+	key string,
+	leaseKey string,
+	token string,
+	value []byte,
+	ttl time.Duration,
+) (bool, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	lease, ok := b.lookup(leaseKey)
+	if !ok || string(lease.value) != token {
+		return false, nil
+	}
+	b.remove(leaseKey)
+
+	stored := make([]byte, len(value))
+	copy(stored, value)
+	b.store(key, &memoryEntry{
+		value:     stored,
+		expiresAt: b.expiresAt(ttl),
+	})
+	return true, nil
+}
+
+func (b *MemoryBackend) MLease(ctx context.Context, leases ...KeyValue) ([]bool, error) {
+	granted := make([]bool, len(leases))
+	for i, lease := range leases {
+		granted[i], _ = b.Lease(ctx, lease.Key, string(lease.Value), lease.TTL)
+	}
+	return granted, nil
+}
+
+func (b *MemoryBackend) MSetLeased(ctx context.Context, items ...LeasedKeyValue) ([]bool, error) {
+	set := make([]bool, len(items))
+	for i, item := range items {
+		set[i], _ = b.SetLeased(ctx, item.Key, item.LeaseKey, item.Token, item.Value, item.TTL)
+	}
+	return set, nil
+}
+
+// Len returns how many entries are stored, including expired entries that
+// have not been accessed since they expired.
+func (b *MemoryBackend) Len() int {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return len(b.entries)
+}
+
+// Clear removes every entry.
+func (b *MemoryBackend) Clear() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	for key := range b.entries {
+		b.remove(key)
+	}
+}
+
+// Bytes returns the total size of the stored keys and values.
+func (b *MemoryBackend) Bytes() int64 {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.bytes
+}
+
+// lookup returns the live entry for key, dropping it if it has expired. The
+// caller must hold mu.
+func (b *MemoryBackend) lookup(key string) (*memoryEntry, bool) {
+	entry, ok := b.entries[key]
+	if !ok {
+		return nil, false
+	}
+	if entry.expired(b.now()) {
+		b.remove(key)
+		return nil, false
+	}
+	b.evictor.accessed(key)
+	return entry, true
+}
+
+// store replaces the entry for key, evicting other entries until it fits. The
+// caller must hold mu.
+func (b *MemoryBackend) store(key string, entry *memoryEntry) {
+	b.remove(key)
+
+	size := entrySize(key, entry.value)
+	for b.full(size) {
+		victim, ok := b.evictor.victim()
+		if !ok {
+			break
+		}
+		evicted := b.entries[victim]
+		b.remove(victim)
+		if b.opts.onEvict != nil {
+			b.opts.onEvict(victim, evicted.value)
+		}
+	}
+
+	b.entries[key] = entry
+	b.bytes += size
+	b.evictor.added(key)
+}
+
+// full reports whether adding an entry of size would exceed a limit.
+func (b *MemoryBackend) full(size int64) bool {
+	if b.opts.maxEntries > 0 && len(b.entries)+1 > b.opts.maxEntries {
+		return true
+	}
+	return b.opts.maxBytes > 0 && b.bytes+size > b.opts.maxBytes
+}
+
+// remove drops key if it is stored. The caller must hold mu.
+func (b *MemoryBackend) remove(key string) {
+	entry, ok := b.entries[key]
+	if !ok {
+		return
+	}
+	delete(b.entries, key)
+	b.bytes -= entrySize(key, entry.value)
+	b.evictor.removed(key)
+}
+
+func (b *MemoryBackend) expiresAt(ttl time.Duration) time.Time {
+	if ttl <= 0 {
+		return time.Time{}
+	}
+	return b.now().Add(ttl)
+}
@@ -0,0 +1,115 @@
+package cache
+
+import (
+	"context"
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+type fakeClock struct {
+	now time.Time
+}
+
+func (c *fakeClock) Now() time.Time {
+	return c.now
+}
+
+func (c *fakeClock) Advance(d time.Duration) {
+	c.now = c.now.Add(d)
+}
+
+func newTestMemoryBackend() (*MemoryBackend, *fakeClock) {
+	clock := &fakeClock{now: time.Now()}
+	b := NewMemoryBackend()
+	b.now = clock.Now
+	return b, clock
+}
+
+func TestMemoryBackend(t *testing.T) {
+	ctx := context.Background()
+
+	tests := []struct {
+		desc string
+		run  func(t *testing.T, b *MemoryBackend, clock *fakeClock)
+	}{
+		{
+			desc: "happy path: set then get",
+			run: func(t *testing.T, b *MemoryBackend, clock *fakeClock) {
+				assert.NoError(t, b.Set(ctx, "a", []byte("1"), 0))
+				value, err := b.Get(ctx, "a")
+				assert.NoError(t, err)
+				assert.Equal(t, []byte("1"), value)
+			},
+		},
+		{
+			desc: "missing key returns MissError",
+			run: func(t *testing.T, b *MemoryBackend, clock *fakeClock) {
+				_, err := b.Get(ctx, "a")
+				assert.ErrorIs(t, err, MissError)
+			},
+		},
+		{
+			desc: "entry expires after its ttl",
+			run: func(t *testing.T, b *MemoryBackend, clock *fakeClock) {
+				assert.NoError(t, b.Set(ctx, "a", []byte("1"), time.Minute))
+				clock.Advance(time.Minute - time.Second)
+				_, err := b.Get(ctx, "a")
+				assert.NoError(t, err)
+
+				clock.Advance(time.Second)
+				_, err = b.Get(ctx, "a")
+				assert.ErrorIs(t, err, MissError)
+				assert.Equal(t, 0, b.Len())
+			},
+		},
+		{
+			desc: "expire extends the ttl",
+			run: func(t *testing.T, b *MemoryBackend, clock *fakeClock) {
+				assert.NoError(t, b.Set(ctx, "a", []byte("1"), time.Minute))
+				clock.Advance(30 * time.Second)
+				assert.NoError(t, b.Expire(ctx, "a", time.Minute))
+				clock.Advance(45 * time.Second)
+				_, err := b.Get(ctx, "a")
+				assert.NoError(t, err)
+			},
+		},
+		{
+			desc: "mget returns nil for misses",
+			run: func(t *testing.T, b *MemoryBackend, clock *fakeClock) {
+				assert.NoError(t, b.Set(ctx, "a", []byte("1"), 0))
+				assert.NoError(t, b.Set(ctx, "c", []byte("3"), 0))
+				values, err := b.MGet(ctx, "a", "b", "c")
+				assert.NoError(t, err)
+				assert.Equal(t, [][]byte{[]byte("1"), nil, []byte("3")}, values)
+			},
+		},
+		{
+			desc: "delete removes every key",
+			run: func(t *testing.T, b *MemoryBackend, clock *fakeClock) {
+				assert.NoError(t, b.Set(ctx, "a", []byte("1"), 0))
+				assert.NoError(t, b.Set(ctx, "b", []byte("2"), 0))
+				assert.NoError(t, b.Delete(ctx, "a", "b"))
+				assert.Equal(t, 0, b.Len())
+			},
+		},
+		{
+			desc: "incr counts from zero",
+			run: func(t *testing.T, b *MemoryBackend, clock *fakeClock) {
+				n, err := b.Incr(ctx, "n")
+				assert.NoError(t, err)
+				assert.Equal(t, int64(1), n)
+				n, err = b.Incr(ctx, "n")
+				assert.NoError(t, err)
+				assert.Equal(t, int64(2), n)
+			},
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.desc, func(t *testing.T) {
+			b, clock := newTestMemoryBackend()
+			tc.run(t, b, clock)
+		})
+	}
+}
@@ -0,0 +1,269 @@
+package cache
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// latencyBuckets are the upper bounds, in seconds, of the operation latency
+// histograms. They range from in-process hits to slow Redis round trips and
+// loads from the source of truth.
+var latencyBuckets = []float64{
+	0.0001, 0.00025, 0.0005, 0.001, 0.0025, 0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5,
+}
+
+// Operations, as labelled in the metrics.
+const (
+	opRead           = "read"
+	opReadMany       = "read_many"
+	opWrite          = "write"
+	opWriteMany      = "write_many"
+	opFillMany       = "fill_many"
+	opWriteMissing   = "write_missing"
+	opInvalidate     = "invalidate"
+	opInvalidateMany = "invalidate_many"
+	opInvalidateTag  = "invalidate_tag"
+	opLoad           = "load"
+)
+
+type metricKind int
+
+const (
+	counterMetric metricKind = iota
+	histogramMetric
+)
+
+type metricDesc struct {
+	name string
+	help string
+	kind metricKind
+}
+
+var (
+	hitsDesc = metricDesc{
+		name: "cache_hits_total",
+		help: "Reads served from the cache, stale-while-revalidate hits included.",
+	}
+	missesDesc = metricDesc{
+		name: "cache_misses_total",
+		help: "Reads that had to go to the source of truth.",
+	}
+	errorsDesc = metricDesc{
+		name: "cache_errors_total",
+		help: "Cache operations that failed.",
+	}
+	timeoutsDesc = metricDesc{
+		name: "cache_timeouts_total",
+		help: "Backend calls that ran out of time, see WithTimeouts.",
+	}
+	evictionsDesc = metricDesc{
+		name: "cache_evictions_total",
+		help: "Entries dropped by a bounded in-memory backend to make room.",
+	}
+	encodedBytesDesc = metricDesc{
+		name: "cache_encoded_bytes_total",
+		help: "Size of the values written, before compression.",
+	}
+	storedBytesDesc = metricDesc{
+		name: "cache_stored_bytes_total",
+		help: "Size of the values written, as stored in the backend.",
+	}
+	durationDesc = metricDesc{
+		name: "cache_operation_duration_seconds",
+		help: "Latency of cache operations.",
+		kind: histogramMetric,
+	}
+)
+
+type seriesKey struct {
+	desc      *metricDesc
+	namespace string
+	operation string
+}
+
+type histogram struct {
+	counts []uint64
+	sum    float64
+	count  uint64
+}
+
+// Metrics collects hits, misses, errors, timeouts, evictions and operation
+// latencies of the caches built WithMetrics, labelled by namespace, and serves
+// them in the Prometheus text format.
+type Metrics struct {
+	mu         sync.Mutex
+	counters   map[seriesKey]float64
+	histograms map[seriesKey]*histogram
+}
+
+func NewMetrics() *Metrics {
+	return &Metrics{
+		counters:   make(map[seriesKey]float64),
+		histograms: make(map[seriesKey]*histogram),
+	}
+}
+
+func (m *Metrics) add(desc *metricDesc, namespace string, operation string, value float64) {
+	if m == nil {
+		return
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.counters[seriesKey{desc: desc, namespace: namespace, operation: operation}] += value
+}
+
+func (m *Metrics) observe(desc *metricDesc, namespace string, operation string, d time.Duration) {
+	if m == nil {
+		return
+	}
+	key := seriesKey{desc: desc, namespace: namespace, operation: operation}
+	seconds := d.Seconds()
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	h, ok := m.histograms[key]
+	if !ok {
+		h = &histogram{counts: make([]uint64, len(latencyBuckets))}
+		m.histograms[key] = h
+	}
+	for i, bound := range latencyBuckets {
+		if seconds <= bound {
+			h.counts[i]++
+		}
+	}
+	h.sum += seconds
+	h.count++
+}
+
+// record observes how long operation took since start, and counts it as an
+// error if *err is set. Lost leases and keys missing from the source of truth
+// are expected, and not counted.
+func (c *Cache[T]) record(operation string, start time.Time, err *error) {
+	m := c.opts.metrics
+	if m == nil {
+		return
+	}
+	m.observe(&durationDesc, c.opts.namespace, operation, time.Since(start))
+	if *err != nil && !errors.Is(*err, LeaseLostError) && !errors.Is(*err, NotFoundError) {
+		m.add(&errorsDesc, c.opts.namespace, operation, 1)
+	}
+}
+
+// recordRead counts a read as a hit or a miss.
+func (c *Cache[T]) recordRead(result ReadCacheResult[T]) {
+	if result.CacheHit {
+		c.opts.metrics.add(&hitsDesc, c.opts.namespace, "", 1)
+	} else {
+		c.opts.metrics.add(&missesDesc, c.opts.namespace, "", 1)
+	}
+}
+
+// RecordEviction is an EvictionCallback counting evictions by the namespace of
+// the evicted key, see WithNamespace.
+func (m *Metrics) RecordEviction(key string, value []byte) {
+	m.add(&evictionsDesc, keyNamespace(key), "", 1)
+}
+
+// keyNamespace returns the namespace of a backend key written by a Cache built
+// WithNamespace, or "" for any other key.
+func keyNamespace(key string) string {
+	key = strings.TrimPrefix(key, "lease:")
+	namespace, rest, ok := strings.Cut(key, ":")
+	if !ok || !strings.HasPrefix(rest, "v") {
+		return ""
+	}
+	version, _, ok := strings.Cut(rest[1:], ":")
+	if _, err := strconv.Atoi(version); !ok || err != nil {
+		return ""
+	}
+	return namespace
+}
+
+func (m *Metrics) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	err := m.WritePrometheus(w)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
+
+// WritePrometheus writes every series in the Prometheus text exposition
+// format.
+func (m *Metrics) WritePrometheus(w io.Writer) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	byDesc := make(map[*metricDesc][]seriesKey)
+	for key := range m.counters {
+		byDesc[key.desc] = append(byDesc[key.desc], key)
+	}
+	for key := range m.histograms {
+		byDesc[key.desc] = append(byDesc[key.desc], key)
+	}
+	descs := make([]*metricDesc, 0, len(byDesc))
+	for desc := range byDesc {
+		descs = append(descs, desc)
+	}
+	sort.Slice(descs, func(i, j int) bool {
+		return descs[i].name < descs[j].name
+	})
+
+	bw := bufio.NewWriter(w)
+	for _, desc := range descs {
+		keys := byDesc[desc]
+		sort.Slice(keys, func(i, j int) bool {
+			if keys[i].namespace != keys[j].namespace {
+				return keys[i].namespace < keys[j].namespace
+			}
+			return keys[i].operation < keys[j].operation
+		})
+
+		kind := "counter"
+		if desc.kind == histogramMetric {
+			kind = "histogram"
+		}
+		fmt.Fprintf(bw, "# HELP %s %s\n", desc.name, desc.help)
+		fmt.Fprintf(bw, "# TYPE %s %s\n", desc.name, kind)
+
+		for _, key := range keys {
+			if desc.kind == counterMetric {
+				fmt.Fprintf(bw, "%s%s %s\n", desc.name, key.labels(""), formatFloat(m.counters[key]))
+				continue
+			}
+
+			h := m.histograms[key]
+			for i, bound := range latencyBuckets {
+				fmt.Fprintf(bw, "%s_bucket%s %d\n", desc.name, key.labels(formatFloat(bound)), h.counts[i])
+			}
+			fmt.Fprintf(bw, "%s_bucket%s %d\n", desc.name, key.labels("+Inf"), h.count)
+			fmt.Fprintf(bw, "%s_sum%s %s\n", desc.name, key.labels(""), formatFloat(h.sum))
+			fmt.Fprintf(bw, "%s_count%s %d\n", desc.name, key.labels(""), h.count)
+		}
+	}
+	return bw.Flush()
+}
+
+// labels formats the labels of the series, with an le label for histogram
+// buckets when le is set.
+func (k seriesKey) labels(le string) string {
+	labels := []string{"namespace=" + strconv.Quote(k.namespace)}
+	if k.operation != "" {
+		labels = append(labels, "operation="+strconv.Quote(k.operation))
+	}
+	if le != "" {
+		labels = append(labels, "le="+strconv.Quote(le))
+	}
+	return "{" + strings.Join(labels, ",") + "}"
+}
+
+func formatFloat(f float64) string {
+	return strconv.FormatFloat(f, 'g', -1, 64)
+}
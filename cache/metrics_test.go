@@ -0,0 +1,66 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestMetrics(t *testing.T) {
+	ctx := context.Background()
+	metrics := NewMetrics()
+	b := NewMemoryBackend(WithMaxEntries(1), WithEvictionCallback(metrics.RecordEviction))
+	c := New[testItem](b, WithNamespace("item", 1), WithMetrics(metrics))
+
+	_, err := c.ReadItem(ctx, "a")
+	assert.NoError(t, err)
+	assert.NoError(t, c.WriteItem(ctx, "a", &testItem{Name: "a"}))
+	_, err = c.ReadItem(ctx, "a")
+	assert.NoError(t, err)
+	assert.NoError(t, c.WriteItem(ctx, "b", &testItem{Name: "b"}))
+	_, err = c.Fetch(ctx, "c", func(ctx context.Context, key string) (*testItem, error) {
+		return nil, errors.New("source unavailable")
+	})
+	assert.Error(t, err)
+
+	rec := httptest.NewRecorder()
+	metrics.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	assert.Equal(t, http.StatusOK, rec.Code)
+	body := rec.Body.String()
+
+	for _, line := range []string{
+		"# TYPE cache_hits_total counter",
+		`cache_hits_total{namespace="item"} 1`,
+		`cache_misses_total{namespace="item"} 2`,
+		`cache_evictions_total{namespace="item"} 1`,
+		`cache_errors_total{namespace="item",operation="load"} 1`,
+		"# TYPE cache_operation_duration_seconds histogram",
+		`cache_operation_duration_seconds_bucket{namespace="item",operation="write",le="+Inf"} 2`,
+		`cache_operation_duration_seconds_count{namespace="item",operation="read"} 3`,
+	} {
+		assert.Contains(t, strings.Split(body, "\n"), line)
+	}
+}
+
+func Test_keyNamespace(t *testing.T) {
+	tests := []struct {
+		desc     string
+		key      string
+		expected string
+	}{
+		{desc: "namespaced key", key: "todo:v2:abc", expected: "todo"},
+		{desc: "lease of a namespaced key", key: "lease:todo:v2:abc", expected: "todo"},
+		{desc: "bare key", key: "abc", expected: ""},
+		{desc: "key without a version", key: "tag:user:1", expected: ""},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.desc, func(t *testing.T) {
+			assert.Equal(t, tc.expected, keyNamespace(tc.key))
+		})
+	}
+}
@@ -0,0 +1,277 @@
+package cache
+
+import (
+	"math/rand/v2"
+	"time"
+)
+
+type cacheOptions struct {
+	defaultTTL time.Duration
+	ttlJitter  time.Duration
+	sliding    bool
+	beta       float64
+
+	staleWhileRevalidate time.Duration
+	staleIfError         time.Duration
+
+	negativeTTL time.Duration
+	leaseTTL    time.Duration
+
+	namespace     string
+	schemaVersion int
+
+	codec                Codec
+	compressionThreshold int
+	keyring              *Keyring
+	plaintextReads       bool
+
+	metrics *Metrics
+
+	readTimeout  time.Duration
+	writeTimeout time.Duration
+	retries      int
+	retryBackoff time.Duration
+}
+
+type CacheOpt func(o *cacheOptions)
+
+// WithDefaultTTL sets the TTL of entries written without WithTTL. A TTL of zero
+// keeps entries until they are invalidated.
+func WithDefaultTTL(ttl time.Duration) CacheOpt {
+	return func(o *cacheOptions) {
+		o.defaultTTL = ttl
+	}
+}
+
+// WithTTLJitter adds a random duration of up to jitter to every TTL so that
+// entries written together do not all expire together.
+func WithTTLJitter(jitter time.Duration) CacheOpt {
+	return func(o *cacheOptions) {
+		o.ttlJitter = jitter
+	}
+}
+
+// WithSlidingExpiration resets an entry's TTL to the default TTL every time it
+// is read, so entries only expire once they stop being read.
+func WithSlidingExpiration() CacheOpt {
+	return func(o *cacheOptions) {
+		o.sliding = true
+	}
+}
+
+// WithEarlyRecomputation makes reads treat an entry as a miss shortly before it
+// expires, with a probability that grows with how long its value took to
+// compute (see WithComputeCost). beta scales how early; 1 is a good default.
+// It prevents stampedes of loads when a popular entry expires, and has no
+// effect with sliding expiration.
+func WithEarlyRecomputation(beta float64) CacheOpt {
+	return func(o *cacheOptions) {
+		o.beta = beta
+	}
+}
+
+// WithStaleWhileRevalidate keeps serving an entry for window after its TTL
+// (its soft TTL) while it is refreshed in the background.
+func WithStaleWhileRevalidate(window time.Duration) CacheOpt {
+	return func(o *cacheOptions) {
+		o.staleWhileRevalidate = window
+	}
+}
+
+// WithStaleIfError keeps an entry for window after its TTL so that it can be
+// served when the source of truth fails. The entry's TTL plus the larger of
+// the two stale windows is its hard TTL, after which it is dropped.
+func WithStaleIfError(window time.Duration) CacheOpt {
+	return func(o *cacheOptions) {
+		o.staleIfError = window
+	}
+}
+
+// WithNegativeTTL enables caching keys the source of truth does not have as
+// tombstones, for ttl. It should be short, as a key created meanwhile is only
+// visible once its tombstone expires or is invalidated.
+func WithNegativeTTL(ttl time.Duration) CacheOpt {
+	return func(o *cacheOptions) {
+		o.negativeTTL = ttl
+	}
+}
+
+// WithLeases hands out a Lease with every miss, see Lease. A lease that is not
+// used expires after ttl, which should cover loading the value.
+func WithLeases(ttl time.Duration) CacheOpt {
+	return func(o *cacheOptions) {
+		o.leaseTTL = ttl
+	}
+}
+
+// WithNamespace prefixes every key of the cache with namespace and the schema
+// version of its values, as in todo:v2:<id>, so that it does not collide with
+// other caches on the same backend. Bumping version when the stored type
+// changes makes the entries written with the old one invisible, and they are
+// left to expire. Tags are not namespaced, see InvalidateTag.
+func WithNamespace(namespace string, version int) CacheOpt {
+	return func(o *cacheOptions) {
+		o.namespace = namespace
+		o.schemaVersion = version
+	}
+}
+
+// WithCodec sets the codec values are written with. Values written with any
+// built-in codec, or with codec itself, can still be read, so a cache can be
+// switched to another codec while old entries are live. Defaults to
+// JSONCodec.
+func WithCodec(codec Codec) CacheOpt {
+	return func(o *cacheOptions) {
+		o.codec = codec
+	}
+}
+
+// WithCompression gzips values whose encoding is at least threshold bytes.
+// Values are stored uncompressed when that does not make them smaller.
+func WithCompression(threshold int) CacheOpt {
+	return func(o *cacheOptions) {
+		o.compressionThreshold = threshold
+	}
+}
+
+// WithEncryption encrypts values with the current key of keyring before they
+// reach the backend, after they are compressed. Values are bound to the key
+// they are stored under. Values that are not encrypted are rejected with
+// PlaintextError, see WithPlaintextReads. Reads log values that do not decrypt
+// or are rejected, and drop them as misses.
+func WithEncryption(keyring *Keyring) CacheOpt {
+	return func(o *cacheOptions) {
+		o.keyring = keyring
+	}
+}
+
+// WithPlaintextReads lets a cache built WithEncryption read values that are
+// not encrypted, such as values written before encryption was turned on. It
+// is meant for the migration only: anyone able to write to the backend can
+// then plant values the cache trusts. Alternatively, bump the schema version
+// of WithNamespace along with turning encryption on.
+func WithPlaintextReads() CacheOpt {
+	return func(o *cacheOptions) {
+		o.plaintextReads = true
+	}
+}
+
+// WithMetrics records the cache's hits, misses, errors and operation
+// latencies in metrics, labelled by the namespace set with WithNamespace.
+func WithMetrics(metrics *Metrics) CacheOpt {
+	return func(o *cacheOptions) {
+		o.metrics = metrics
+	}
+}
+
+// WithTimeouts bounds every call the cache makes to its backend, retries
+// included: Get and MGet by read, and writes, deletes, counters and leases by
+// write. A read that times out is a miss, so a slow backend cannot use up the
+// caller's deadline, and any other call that times out fails with
+// TimeoutError. Zero leaves calls bounded by the caller's context alone.
+func WithTimeouts(read time.Duration, write time.Duration) CacheOpt {
+	return func(o *cacheOptions) {
+		o.readTimeout = read
+		o.writeTimeout = write
+	}
+}
+
+// WithRetries retries failed backend calls up to retries times, waiting a
+// random duration up to backoff before the first retry and doubling backoff
+// after each one. Only idempotent calls are retried, never Incr, Lease or
+// SetLeased, and retries stop at the timeout set with WithTimeouts. Calls a
+// CircuitBreakerBackend rejects with CircuitOpenError are not retried, and the
+// breaker counts the failed attempts of one call as a single failure.
+func WithRetries(retries int, backoff time.Duration) CacheOpt {
+	return func(o *cacheOptions) {
+		o.retries = retries
+		o.retryBackoff = backoff
+	}
+}
+
+type writeOptions struct {
+	ttl         time.Duration
+	computeCost time.Duration
+	lease       *Lease
+	tags        []string
+}
+
+type WriteOpt func(o *writeOptions)
+
+// WithTTL overrides the cache's default TTL for a single write.
+func WithTTL(ttl time.Duration) WriteOpt {
+	return func(o *writeOptions) {
+		o.ttl = ttl
+	}
+}
+
+// staleWindow is how long entries are kept after their TTL.
+func (o *cacheOptions) staleWindow() time.Duration {
+	return max(o.staleWhileRevalidate, o.staleIfError)
+}
+
+func newCacheOptions(opts []CacheOpt) cacheOptions {
+	o := cacheOptions{codec: JSONCodec{}}
+	for _, opt := range opts {
+		opt(&o)
+	}
+	return o
+}
+
+// expiration returns the TTL to store an entry with, given the TTL it was
+// written or read with. Zero means the entry does not expire.
+func (o *cacheOptions) expiration(ttl time.Duration) time.Duration {
+	if ttl <= 0 || o.ttlJitter <= 0 {
+		return ttl
+	}
+	return ttl + rand.N(o.ttlJitter)
+}
+
+// WithComputeCost records how long the value took to compute, for early
+// recomputation. Fetch and Get record it themselves.
+func WithComputeCost(cost time.Duration) WriteOpt {
+	return func(o *writeOptions) {
+		o.computeCost = cost
+	}
+}
+
+// WithLease fills a key under the lease handed out with its miss. The write
+// fails with LeaseLostError if the lease was not granted, or was cancelled by
+// an invalidation or another write. A nil lease writes unconditionally.
+func WithLease(lease *Lease) WriteOpt {
+	return func(o *writeOptions) {
+		o.lease = lease
+	}
+}
+
+// WithTags tags the written entry, so that it is dropped by an InvalidateTag
+// of any of tags.
+func WithTags(tags ...string) WriteOpt {
+	return func(o *writeOptions) {
+		o.tags = append(o.tags, tags...)
+	}
+}
+
+type readOptions struct {
+	leaseTags []string
+}
+
+type ReadOpt func(o *readOptions)
+
+// WithLeaseTags names the tags a miss will be filled WithTags. The lease handed
+// out with the miss records their versions, and the fill is tagged with those
+// rather than with the versions current when it is written, so that an
+// InvalidateTag while the value is being loaded drops the fill.
+func WithLeaseTags(tags ...string) ReadOpt {
+	return func(o *readOptions) {
+		o.leaseTags = append(o.leaseTags, tags...)
+	}
+}
+
+func newReadOptions(opts []ReadOpt) readOptions {
+	var o readOptions
+	for _, opt := range opts {
+		opt(&o)
+	}
+	return o
+}
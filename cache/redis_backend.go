@@ -0,0 +1,183 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"github.com/go-redis/redis/v8"
+	"time"
+)
+
+var _ Backend = (*RedisBackend)(nil)
+
+// setLeasedScript sets KEYS[1] to ARGV[2] with a TTL of ARGV[3] milliseconds,
+// if KEYS[2] still holds the lease token ARGV[1].
+var setLeasedScript = redis.NewScript(`
+if redis.call("GET", KEYS[2]) ~= ARGV[1] then
+	return 0
+end
+redis.call("DEL", KEYS[2])
+if tonumber(ARGV[3]) > 0 then
+	redis.call("SET", KEYS[1], ARGV[2], "PX", ARGV[3])
+else
+	redis.call("SET", KEYS[1], ARGV[2])
+end
+return 1
+`)
+
+type RedisBackend struct {
+	redisClient *redis.Client
+}
+
+func NewRedisBackend(redisClient *redis.Client) *RedisBackend {
+	return &RedisBackend{
+		redisClient: redisClient,
+	}
+}
+
+func (b *RedisBackend) Get(ctx context.Context, key string) ([]byte, error) {
+	value, err := b.redisClient.Get(ctx, key).Bytes()
+	if errors.Is(err, redis.Nil) {
+		return nil, MissError
+	}
+	if err != nil {
+		return nil, err
+	}
+	return value, nil
+}
+
+func (b *RedisBackend) MGet(ctx context.Context, keys ...string) ([][]byte, error) {
+	if len(keys) == 0 {
+		return nil, nil
+	}
+
+	results, err := b.redisClient.MGet(ctx, keys...).Result()
+	if err != nil {
+		return nil, err
+	}
+
+	values := make([][]byte, len(keys))
+	for i, result := range results {
+		if s, ok := result.(string); ok {
+			values[i] = []byte(s)
+		}
+	}
+	return values, nil
+}
+
+func (b *RedisBackend) Set(ctx context.Context, key string, value []byte, ttl time.Duration) error {
+	return b.redisClient.Set(ctx, key, value, ttl).Err()
+}
+
+func (b *RedisBackend) MSet(ctx context.Context, items ...KeyValue) error {
+	if len(items) == 0 {
+		return nil
+	}
+
+	// MSET cannot set a TTL, so the SETs are pipelined instead.
+	_, err := b.redisClient.Pipelined(ctx, func(pipe redis.Pipeliner) error {
+		for _, item := range items {
+			pipe.Set(ctx, item.Key, item.Value, item.TTL)
+		}
+		return nil
+	})
+	return err
+}
+
+func (b *RedisBackend) Delete(ctx context.Context, keys ...string) error {
+	if len(keys) == 0 {
+		return nil
+	}
+	return b.redisClient.Del(ctx, keys...).Err()
+}
+
+func (b *RedisBackend) Expire(ctx context.Context, key string, ttl time.Duration) error {
+	if ttl <= 0 {
+		return b.redisClient.Persist(ctx, key).Err()
+	}
+	return b.redisClient.Expire(ctx, key, ttl).Err()
+}
+
+func (b *RedisBackend) Incr(ctx context.Context, key string) (int64, error) {
+	return b.redisClient.Incr(ctx, key).Result()
+}
+
+func (b *RedisBackend) Lease(ctx context.Context, leaseKey string, token string, ttl time.Duration) (bool, error) {
+	return b.redisClient.SetNX(ctx, leaseKey, token, ttl).Result()
+}
+
+func (b *RedisBackend) SetLeased(
+	ctx context.Context,
+	key string,
+	leaseKey string,
+	token string,
+	value []byte,
+	ttl time.Duration,
+) (bool, error) {
+	set, err := setLeasedScript.Run(ctx, b.redisClient,
+		[]string{key, leaseKey},
+		token, value, ttl.Milliseconds(),
+	).Int()
+	if err != nil {
+		return false, err
+	}
+	return set == 1, nil
+}
+
+func (b *RedisBackend) MLease(ctx context.Context, leases ...KeyValue) ([]bool, error) {
+	if len(leases) == 0 {
+		return nil, nil
+	}
+
+	cmds := make([]*redis.BoolCmd, len(leases))
+	_, err := b.redisClient.Pipelined(ctx, func(pipe redis.Pipeliner) error {
+		for i, lease := range leases {
+			cmds[i] = pipe.SetNX(ctx, lease.Key, lease.Value, lease.TTL)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	granted := make([]bool, len(leases))
+	for i, cmd := range cmds {
+		granted[i] = cmd.Val()
+	}
+	return granted, nil
+}
+
+// MSetLeased pipelines one run of the SetLeased script per item. The script is
+// loaded first, so that the pipeline can call it by its hash.
+func (b *RedisBackend) MSetLeased(ctx context.Context, items ...LeasedKeyValue) ([]bool, error) {
+	if len(items) == 0 {
+		return nil, nil
+	}
+
+	err := setLeasedScript.Load(ctx, b.redisClient).Err()
+	if err != nil {
+		return nil, err
+	}
+	cmds := make([]*redis.Cmd, len(items))
+	_, err = b.redisClient.Pipelined(ctx, func(pipe redis.Pipeliner) error {
+		for i, item := range items {
+			cmds[i] = setLeasedScript.EvalSha(ctx, pipe,
+				[]string{item.Key, item.LeaseKey},
+				item.Token, item.Value, item.TTL.Milliseconds(),
+			)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	set := make([]bool, len(items))
+	for i, cmd := range cmds {
+		n, err := cmd.Int()
+		if err != nil {
+			return nil, err
+		}
+		set[i] = n == 1
+	}
+	return set, nil
+}
@@ -0,0 +1,90 @@
+package cache
+
+import (
+	"context"
+	"sync"
+)
+
+type flight[T any] struct {
+	done chan struct{}
+	data *T
+	err  error
+
+	// waiters is the number of callers still waiting. The load is cancelled
+	// when it drops to zero.
+	waiters int
+	cancel  context.CancelFunc
+}
+
+// flightGroup collapses concurrent loads of the same key into one.
+type flightGroup[T any] struct {
+	mu      sync.Mutex
+	flights map[string]*flight[T]
+}
+
+// do runs load once for all callers asking for key at the same time and
+// shares its result. load runs with a context detached from any one caller,
+// so a caller whose ctx is done returns ctx.Err() without failing the others.
+// The load itself is only cancelled once every caller has given up.
+func (g *flightGroup[T]) do(
+	ctx context.Context,
+	key string,
+	load func(ctx context.Context) (*T, error),
+) (*T, error) {
+	g.mu.Lock()
+	if g.flights == nil {
+		g.flights = make(map[string]*flight[T])
+	}
+	f, ok := g.flights[key]
+	if ok {
+		f.waiters++
+	} else {
+		loadCtx, cancel := context.WithCancel(context.WithoutCancel(ctx))
+		f = &flight[T]{
+			done:    make(chan struct{}),
+			waiters: 1,
+			cancel:  cancel,
+		}
+		g.flights[key] = f
+		go g.run(loadCtx, key, f, load)
+	}
+	g.mu.Unlock()
+
+	select {
+	case <-f.done:
+		return f.data, f.err
+	case <-ctx.Done():
+		g.mu.Lock()
+		f.waiters--
+		if f.waiters == 0 {
+			f.cancel()
+			g.forget(key, f)
+		}
+		g.mu.Unlock()
+		return nil, ctx.Err()
+	}
+}
+
+func (g *flightGroup[T]) run(
+	ctx context.Context,
+	key string,
+	f *flight[T],
+	load func(ctx context.Context) (*T, error),
+) {
+	defer f.cancel()
+
+	f.data, f.err = load(ctx)
+
+	g.mu.Lock()
+	g.forget(key, f)
+	g.mu.Unlock()
+	close(f.done)
+}
+
+// forget removes f so that the next caller starts a new load. The caller must
+// hold mu.
+func (g *flightGroup[T]) forget(key string, f *flight[T]) {
+	if g.flights[key] == f {
+		delete(g.flights, key)
+	}
+}
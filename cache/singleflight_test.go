@@ -0,0 +1,113 @@
+package cache
+
+import (
+	"context"
+	"github.com/stretchr/testify/assert"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestCache_Coalesce(t *testing.T) {
+	c := New[testItem](NewMemoryBackend())
+	release := make(chan struct{})
+	var loads atomic.Int32
+
+	load := func(ctx context.Context) (*testItem, error) {
+		loads.Add(1)
+		<-release
+		return &testItem{Name: "a"}, nil
+	}
+
+	const callers = 10
+	var wg sync.WaitGroup
+	results := make([]*testItem, callers)
+	for i := range callers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			data, err := c.Coalesce(context.Background(), "a", load)
+			assert.NoError(t, err)
+			results[i] = data
+		}()
+	}
+
+	// Let every caller join the flight before the load finishes.
+	assert.Eventually(t, func() bool {
+		c.flights.mu.Lock()
+		defer c.flights.mu.Unlock()
+		f, ok := c.flights.flights["a"]
+		return ok && f.waiters == callers
+	}, time.Second, time.Millisecond)
+	close(release)
+	wg.Wait()
+
+	assert.Equal(t, int32(1), loads.Load())
+	for _, data := range results {
+		assert.Equal(t, &testItem{Name: "a"}, data)
+	}
+}
+
+func TestCache_CoalesceCancellation(t *testing.T) {
+	c := New[testItem](NewMemoryBackend())
+	started := make(chan struct{})
+	release := make(chan struct{})
+	loadCancelled := make(chan struct{})
+
+	load := func(ctx context.Context) (*testItem, error) {
+		close(started)
+		select {
+		case <-release:
+			return &testItem{Name: "a"}, nil
+		case <-ctx.Done():
+			close(loadCancelled)
+			return nil, ctx.Err()
+		}
+	}
+
+	cancelledCtx, cancel := context.WithCancel(context.Background())
+	cancelledErr := make(chan error)
+	go func() {
+		_, err := c.Coalesce(cancelledCtx, "a", load)
+		cancelledErr <- err
+	}()
+	<-started
+
+	waiterResult := make(chan *testItem)
+	go func() {
+		data, err := c.Coalesce(context.Background(), "a", load)
+		assert.NoError(t, err)
+		waiterResult <- data
+	}()
+	assert.Eventually(t, func() bool {
+		c.flights.mu.Lock()
+		defer c.flights.mu.Unlock()
+		return c.flights.flights["a"].waiters == 2
+	}, time.Second, time.Millisecond)
+
+	cancel()
+	assert.ErrorIs(t, <-cancelledErr, context.Canceled)
+
+	// The remaining caller still gets the shared result.
+	close(release)
+	assert.Equal(t, &testItem{Name: "a"}, <-waiterResult)
+
+	// Once every caller has gone, the load is cancelled.
+	ctx, cancelLast := context.WithCancel(context.Background())
+	started = make(chan struct{})
+	release = make(chan struct{})
+	lastErr := make(chan error)
+	go func() {
+		_, err := c.Coalesce(ctx, "b", load)
+		lastErr <- err
+	}()
+	<-started
+	cancelLast()
+	assert.ErrorIs(t, <-lastErr, context.Canceled)
+	select {
+	case <-loadCancelled:
+	case <-time.After(time.Second):
+		t.Fatal("load was not cancelled after every caller left")
+	}
+}
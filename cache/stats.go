@@ -0,0 +1,55 @@
+package cache
+
+import "sync/atomic"
+
+// Stats are counters kept by a Cache since it was built.
+type Stats struct {
+	// Writes is how many values were encoded for the backend.
+	Writes int64
+	// CompressedWrites is how many of them were compressed.
+	CompressedWrites int64
+	// EncodedBytes is the size of the values before compression.
+	EncodedBytes int64
+	// StoredBytes is the size of the values as written to the backend.
+	StoredBytes int64
+}
+
+// CompressionRatio is how many times smaller compression made the written
+// values, 1 if nothing was compressed.
+func (s Stats) CompressionRatio() float64 {
+	if s.StoredBytes == 0 {
+		return 1
+	}
+	return float64(s.EncodedBytes) / float64(s.StoredBytes)
+}
+
+type cacheStats struct {
+	writes           atomic.Int64
+	compressedWrites atomic.Int64
+	encodedBytes     atomic.Int64
+	storedBytes      atomic.Int64
+}
+
+// Stats returns a snapshot of the cache's counters.
+func (c *Cache[T]) Stats() Stats {
+	return Stats{
+		Writes:           c.stats.writes.Load(),
+		CompressedWrites: c.stats.compressedWrites.Load(),
+		EncodedBytes:     c.stats.encodedBytes.Load(),
+		StoredBytes:      c.stats.storedBytes.Load(),
+	}
+}
+
+// recordWrite counts a value that was encoded to encoded bytes and stored as
+// stored bytes.
+func (c *Cache[T]) recordWrite(encoded int, stored int) {
+	c.stats.writes.Add(1)
+	if stored < encoded {
+		c.stats.compressedWrites.Add(1)
+	}
+	c.stats.encodedBytes.Add(int64(encoded))
+	c.stats.storedBytes.Add(int64(stored))
+
+	c.opts.metrics.add(&encodedBytesDesc, c.opts.namespace, "", float64(encoded))
+	c.opts.metrics.add(&storedBytesDesc, c.opts.namespace, "", float64(stored))
+}
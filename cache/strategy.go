@@ -6,9 +6,36 @@ type Strategy int
 // TODO Describe the pros and cons here and in README.md
 const (
 	UnsetStrategy Strategy = iota
+	// WriteAround writes only to the database and invalidates the cache. Reads
+	// fill the cache once a key has missed a number of times.
 	WriteAround
+	// WriteThrough writes to the database and then to the cache on every
+	// create and update, so reads after a write are served from the cache.
 	WriteThrough
+	// WriteBack acknowledges writes once they are in the cache and a journal,
+	// and flushes them to the database in batches in the background.
 	WriteBack
+	// ReadThrough lets the cache load misses itself through a Loader, see
+	// Cache.Get and Cache.Fetch. Writes go to the database and invalidate.
 	ReadThrough
 	CacheAside
 )
+
+func (s Strategy) String() string {
+	switch s {
+	case UnsetStrategy:
+		return "unset"
+	case WriteAround:
+		return "write_around"
+	case WriteThrough:
+		return "write_through"
+	case WriteBack:
+		return "write_back"
+	case ReadThrough:
+		return "read_through"
+	case CacheAside:
+		return "cache_aside"
+	default:
+		return "unknown"
+	}
+}
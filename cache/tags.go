@@ -0,0 +1,75 @@
+package cache
+
+import (
+	"context"
+	"go.opentelemetry.io/otel/attribute"
+	"maps"
+	"strconv"
+)
+
+func tagKey(tag string) string {
+	return "tag:" + tag
+}
+
+// InvalidateTag drops every entry written WithTags(tag), in every Cache sharing
+// the backend whatever its namespace. Rather than finding the entries, it bumps a version counter for
+// the tag. Entries remember the version of each of their tags when written,
+// and read as misses once any of them has moved on, until they expire.
+//
+// An entry is tagged with the versions current when it is written, so a value
+// loaded before an InvalidateTag and written after it would survive the
+// invalidation. Fills are safe when they are written under a lease handed out
+// WithLeaseTags, which tags them with the versions from before the load.
+func (c *Cache[T]) InvalidateTag(ctx context.Context, tag string) (err error) {
+	ctx, end := c.begin(ctx, opInvalidateTag, attribute.String("cache.tag", tag))
+	defer end(&err)
+
+	_, err = c.backend.Incr(ctx, tagKey(tag))
+	return err
+}
+
+// tagVersions reads the current version of each tag in one call to the
+// backend. Tags that were never invalidated are at version zero.
+func (c *Cache[T]) tagVersions(ctx context.Context, tags []string) (map[string]int64, error) {
+	if len(tags) == 0 {
+		return nil, nil
+	}
+
+	keys := make([]string, len(tags))
+	for i, tag := range tags {
+		keys[i] = tagKey(tag)
+	}
+	values, err := c.backend.MGet(ctx, keys...)
+	if err != nil {
+		return nil, err
+	}
+
+	versions := make(map[string]int64, len(tags))
+	for i, tag := range tags {
+		if values[i] == nil {
+			versions[tag] = 0
+			continue
+		}
+		version, err := strconv.ParseInt(string(values[i]), 10, 64)
+		if err != nil {
+			return nil, err
+		}
+		versions[tag] = version
+	}
+	return versions, nil
+}
+
+// leasedTagVersions returns the versions to tag an entry filled under lease
+// with: those the lease recorded, and the current version of any other tag.
+func (c *Cache[T]) leasedTagVersions(ctx context.Context, tags []string, lease *Lease) (map[string]int64, error) {
+	versions, unknown := lease.tagVersions(tags)
+	current, err := c.tagVersions(ctx, unknown)
+	if err != nil {
+		return nil, err
+	}
+	if versions == nil {
+		return current, nil
+	}
+	maps.Copy(versions, current)
+	return versions, nil
+}
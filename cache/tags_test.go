@@ -0,0 +1,120 @@
+package cache
+
+import (
+	"context"
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+func TestCache_InvalidateTag(t *testing.T) {
+	ctx := context.Background()
+	b := NewMemoryBackend()
+	c := New[testItem](b, WithDefaultTTL(time.Minute), WithNegativeTTL(time.Minute))
+	// Caches sharing a backend share tags.
+	other := New[[]string](b, WithDefaultTTL(time.Minute))
+
+	assert.NoError(t, c.WriteItem(ctx, "a", &testItem{Name: "a"}, WithTags("user:1")))
+	assert.NoError(t, c.WriteItem(ctx, "b", &testItem{Name: "b"}, WithTags("user:1", "team:1")))
+	assert.NoError(t, c.WriteItem(ctx, "c", &testItem{Name: "c"}, WithTags("user:2")))
+	assert.NoError(t, c.WriteItem(ctx, "d", &testItem{Name: "d"}))
+	assert.NoError(t, c.WriteMissing(ctx, "e", WithTags("user:1")))
+	assert.NoError(t, other.WriteItem(ctx, "list", &[]string{"a", "b"}, WithTags("user:1")))
+
+	assert.NoError(t, c.InvalidateTag(ctx, "user:1"))
+
+	results, err := c.ReadItems(ctx, "a", "b", "c", "d", "e")
+	assert.NoError(t, err)
+	assert.False(t, results["a"].CacheHit)
+	assert.False(t, results["b"].CacheHit)
+	assert.True(t, results["c"].CacheHit)
+	assert.True(t, results["d"].CacheHit)
+	assert.False(t, results["e"].CacheHit)
+
+	list, err := other.ReadItem(ctx, "list")
+	assert.NoError(t, err)
+	assert.False(t, list.CacheHit)
+
+	// Entries written after the invalidation are tagged with the new version.
+	assert.NoError(t, c.WriteItem(ctx, "a", &testItem{Name: "a"}, WithTags("user:1")))
+	result, err := c.ReadItem(ctx, "a")
+	assert.NoError(t, err)
+	assert.True(t, result.CacheHit)
+
+	assert.NoError(t, c.InvalidateTag(ctx, "team:1"))
+	result, err = c.ReadItem(ctx, "a")
+	assert.NoError(t, err)
+	assert.True(t, result.CacheHit)
+}
+
+func TestCache_FetchWithTags(t *testing.T) {
+	ctx := context.Background()
+	c := New[testItem](NewMemoryBackend())
+	loads := 0
+	loader := func(ctx context.Context, key string) (*testItem, error) {
+		loads++
+		return &testItem{Name: key}, nil
+	}
+
+	for range 2 {
+		_, err := c.Fetch(ctx, "a", loader, WithTags("user:1"))
+		assert.NoError(t, err)
+	}
+	assert.Equal(t, 1, loads)
+
+	assert.NoError(t, c.InvalidateTag(ctx, "user:1"))
+	result, err := c.Fetch(ctx, "a", loader, WithTags("user:1"))
+	assert.NoError(t, err)
+	assert.False(t, result.CacheHit)
+	assert.Equal(t, 2, loads)
+}
+
+func TestCache_InvalidateTagDuringLeasedFill(t *testing.T) {
+	ctx := context.Background()
+
+	tests := []struct {
+		desc string
+		fill func(t *testing.T, c *Cache[testItem])
+	}{
+		{
+			desc: "write item",
+			fill: func(t *testing.T, c *Cache[testItem]) {
+				result, err := c.ReadItem(ctx, "a", WithLeaseTags("user:1"))
+				assert.NoError(t, err)
+				assert.NoError(t, c.InvalidateTag(ctx, "user:1"))
+				err = c.WriteItem(ctx, "a", &testItem{Name: "stale"}, WithLease(result.Lease), WithTags("user:1"))
+				assert.NoError(t, err)
+			},
+		},
+		{
+			desc: "fill items",
+			fill: func(t *testing.T, c *Cache[testItem]) {
+				leases := c.AcquireLeases(ctx, []string{"a"}, WithLeaseTags("user:1"))
+				assert.NoError(t, c.InvalidateTag(ctx, "user:1"))
+				err := c.FillItems(ctx, map[string]*testItem{"a": {Name: "stale"}}, leases, WithTags("user:1"))
+				assert.NoError(t, err)
+			},
+		},
+		{
+			desc: "fetch",
+			fill: func(t *testing.T, c *Cache[testItem]) {
+				_, err := c.Fetch(ctx, "a", func(ctx context.Context, key string) (*testItem, error) {
+					assert.NoError(t, c.InvalidateTag(ctx, "user:1"))
+					return &testItem{Name: "stale"}, nil
+				}, WithTags("user:1"))
+				assert.NoError(t, err)
+			},
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.desc, func(t *testing.T) {
+			c := New[testItem](NewMemoryBackend(), WithLeases(time.Minute))
+			tc.fill(t, c)
+
+			result, err := c.ReadItem(ctx, "a")
+			assert.NoError(t, err)
+			assert.False(t, result.CacheHit)
+		})
+	}
+}
@@ -0,0 +1,251 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+var _ Backend = (*TieredBackend)(nil)
+
+// Tier is one level of a TieredBackend.
+type Tier struct {
+	Backend Backend
+	// TTL caps how long entries live in this tier. Zero keeps the TTL the
+	// entry was written with.
+	TTL time.Duration
+}
+
+func (t *Tier) expiration(ttl time.Duration) time.Duration {
+	if t.TTL > 0 && (ttl <= 0 || t.TTL < ttl) {
+		return t.TTL
+	}
+	return ttl
+}
+
+// TieredBackend layers backends from fastest to slowest, typically a small
+// MemoryBackend in front of a RedisBackend. Reads check each tier in turn and
+// copy a hit into the tiers above it. Writes and deletes go to every tier,
+// slowest first, so a faster tier never holds a value the slower tiers have
+// already dropped.
+type TieredBackend struct {
+	tiers []Tier
+}
+
+func NewTieredBackend(tiers ...Tier) *TieredBackend {
+	return &TieredBackend{
+		tiers: tiers,
+	}
+}
+
+func (b *TieredBackend) Get(ctx context.Context, key string) ([]byte, error) {
+	for i, tier := range b.tiers {
+		value, err := tier.Backend.Get(ctx, key)
+		if errors.Is(err, MissError) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		err = b.promote(ctx, i, key, value)
+		if err != nil {
+			return nil, err
+		}
+		return value, nil
+	}
+	return nil, MissError
+}
+
+func (b *TieredBackend) MGet(ctx context.Context, keys ...string) ([][]byte, error) {
+	values := make([][]byte, len(keys))
+	// missing holds the index in keys of every key not found so far.
+	missing := make([]int, len(keys))
+	for i := range keys {
+		missing[i] = i
+	}
+
+	for i, tier := range b.tiers {
+		if len(missing) == 0 {
+			break
+		}
+
+		lookup := make([]string, len(missing))
+		for j, index := range missing {
+			lookup[j] = keys[index]
+		}
+		found, err := tier.Backend.MGet(ctx, lookup...)
+		if err != nil {
+			return nil, err
+		}
+
+		stillMissing := missing[:0]
+		for j, index := range missing {
+			if found[j] == nil {
+				stillMissing = append(stillMissing, index)
+				continue
+			}
+			values[index] = found[j]
+			err = b.promote(ctx, i, keys[index], found[j])
+			if err != nil {
+				return nil, err
+			}
+		}
+		missing = stillMissing
+	}
+	return values, nil
+}
+
+func (b *TieredBackend) Set(ctx context.Context, key string, value []byte, ttl time.Duration) error {
+	for i := len(b.tiers) - 1; i >= 0; i-- {
+		tier := b.tiers[i]
+		err := tier.Backend.Set(ctx, key, value, tier.expiration(ttl))
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (b *TieredBackend) MSet(ctx context.Context, items ...KeyValue) error {
+	for i := len(b.tiers) - 1; i >= 0; i-- {
+		tier := b.tiers[i]
+		tierItems := make([]KeyValue, len(items))
+		for j, item := range items {
+			tierItems[j] = item
+			tierItems[j].TTL = tier.expiration(item.TTL)
+		}
+		err := tier.Backend.MSet(ctx, tierItems...)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (b *TieredBackend) Delete(ctx context.Context, keys ...string) error {
+	for i := len(b.tiers) - 1; i >= 0; i-- {
+		err := b.tiers[i].Backend.Delete(ctx, keys...)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (b *TieredBackend) Expire(ctx context.Context, key string, ttl time.Duration) error {
+	for i := len(b.tiers) - 1; i >= 0; i-- {
+		tier := b.tiers[i]
+		err := tier.Backend.Expire(ctx, key, tier.expiration(ttl))
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Incr only counts in the slowest tier, which is the one shared between
+// instances, and drops copies of the counter read into the tiers above it.
+func (b *TieredBackend) Incr(ctx context.Context, key string) (int64, error) {
+	last := len(b.tiers) - 1
+	n, err := b.tiers[last].Backend.Incr(ctx, key)
+	if err != nil {
+		return 0, err
+	}
+	for _, tier := range b.tiers[:last] {
+		err = tier.Backend.Delete(ctx, key)
+		if err != nil {
+			return 0, err
+		}
+	}
+	return n, nil
+}
+
+// Lease only uses the slowest tier, which is the one shared between
+// instances.
+func (b *TieredBackend) Lease(ctx context.Context, leaseKey string, token string, ttl time.Duration) (bool, error) {
+	return b.tiers[len(b.tiers)-1].Backend.Lease(ctx, leaseKey, token, ttl)
+}
+
+// SetLeased checks the lease in the slowest tier, and copies the value into
+// the tiers above it once it is set there.
+func (b *TieredBackend) SetLeased(
+	ctx context.Context,
+	key string,
+	leaseKey string,
+	token string,
+	value []byte,
+	ttl time.Duration,
+) (bool, error) {
+	last := len(b.tiers) - 1
+	tier := b.tiers[last]
+	set, err := tier.Backend.SetLeased(ctx, key, leaseKey, token, value, tier.expiration(ttl))
+	if err != nil || !set {
+		return set, err
+	}
+
+	for i := last - 1; i >= 0; i-- {
+		tier := b.tiers[i]
+		err := tier.Backend.Set(ctx, key, value, tier.expiration(ttl))
+		if err != nil {
+			return true, err
+		}
+	}
+	return true, nil
+}
+
+// MLease only uses the slowest tier, like Lease.
+func (b *TieredBackend) MLease(ctx context.Context, leases ...KeyValue) ([]bool, error) {
+	return b.tiers[len(b.tiers)-1].Backend.MLease(ctx, leases...)
+}
+
+// MSetLeased checks the leases in the slowest tier, like SetLeased, and copies
+// the values that were set there into the tiers above it.
+func (b *TieredBackend) MSetLeased(ctx context.Context, items ...LeasedKeyValue) ([]bool, error) {
+	last := len(b.tiers) - 1
+	tier := b.tiers[last]
+	tierItems := make([]LeasedKeyValue, len(items))
+	for i, item := range items {
+		tierItems[i] = item
+		tierItems[i].TTL = tier.expiration(item.TTL)
+	}
+	set, err := tier.Backend.MSetLeased(ctx, tierItems...)
+	if err != nil {
+		return nil, err
+	}
+
+	var written []KeyValue
+	for i, item := range items {
+		if set[i] {
+			written = append(written, item.KeyValue)
+		}
+	}
+	if len(written) == 0 {
+		return set, nil
+	}
+	for i := last - 1; i >= 0; i-- {
+		tier := b.tiers[i]
+		tierItems := make([]KeyValue, len(written))
+		for j, item := range written {
+			tierItems[j] = item
+			tierItems[j].TTL = tier.expiration(item.TTL)
+		}
+		err := tier.Backend.MSet(ctx, tierItems...)
+		if err != nil {
+			return set, err
+		}
+	}
+	return set, nil
+}
+
+// promote copies a value found in tier into every tier above it. The remaining
+// TTL of the value is not known, so each tier stores it with its own TTL.
+func (b *TieredBackend) promote(ctx context.Context, tier int, key string, value []byte) error {
+	for i := tier - 1; i >= 0; i-- {
+		err := b.tiers[i].Backend.Set(ctx, key, value, b.tiers[i].TTL)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,162 @@
+package cache
+
+import (
+	"context"
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+func newTestTieredBackend() (*TieredBackend, *MemoryBackend, *MemoryBackend, *fakeClock) {
+	l1, clock := newTestMemoryBackend()
+	l2 := NewMemoryBackend()
+	l2.now = clock.Now
+	return NewTieredBackend(
+		Tier{Backend: l1, TTL: time.Second},
+		Tier{Backend: l2, TTL: time.Hour},
+	), l1, l2, clock
+}
+
+func TestTieredBackend(t *testing.T) {
+	ctx := context.Background()
+
+	tests := []struct {
+		desc string
+		run  func(t *testing.T, b *TieredBackend, l1, l2 *MemoryBackend, clock *fakeClock)
+	}{
+		{
+			desc: "set writes every tier with its own ttl",
+			run: func(t *testing.T, b *TieredBackend, l1, l2 *MemoryBackend, clock *fakeClock) {
+				assert.NoError(t, b.Set(ctx, "a", []byte("1"), time.Minute))
+				clock.Advance(time.Second)
+
+				_, err := l1.Get(ctx, "a")
+				assert.ErrorIs(t, err, MissError)
+				_, err = l2.Get(ctx, "a")
+				assert.NoError(t, err)
+
+				clock.Advance(time.Minute)
+				_, err = l2.Get(ctx, "a")
+				assert.ErrorIs(t, err, MissError)
+			},
+		},
+		{
+			desc: "hit in a lower tier is copied up",
+			run: func(t *testing.T, b *TieredBackend, l1, l2 *MemoryBackend, clock *fakeClock) {
+				assert.NoError(t, l2.Set(ctx, "a", []byte("1"), 0))
+
+				value, err := b.Get(ctx, "a")
+				assert.NoError(t, err)
+				assert.Equal(t, []byte("1"), value)
+
+				value, err = l1.Get(ctx, "a")
+				assert.NoError(t, err)
+				assert.Equal(t, []byte("1"), value)
+			},
+		},
+		{
+			desc: "mget reads each key from the highest tier holding it",
+			run: func(t *testing.T, b *TieredBackend, l1, l2 *MemoryBackend, clock *fakeClock) {
+				assert.NoError(t, l1.Set(ctx, "a", []byte("l1"), 0))
+				assert.NoError(t, l2.Set(ctx, "a", []byte("l2"), 0))
+				assert.NoError(t, l2.Set(ctx, "c", []byte("l2"), 0))
+
+				values, err := b.MGet(ctx, "a", "b", "c")
+				assert.NoError(t, err)
+				assert.Equal(t, [][]byte{[]byte("l1"), nil, []byte("l2")}, values)
+
+				_, err = l1.Get(ctx, "c")
+				assert.NoError(t, err)
+			},
+		},
+		{
+			desc: "mset writes every tier with its own ttl",
+			run: func(t *testing.T, b *TieredBackend, l1, l2 *MemoryBackend, clock *fakeClock) {
+				assert.NoError(t, b.MSet(ctx,
+					KeyValue{Key: "a", Value: []byte("1"), TTL: time.Minute},
+					KeyValue{Key: "b", Value: []byte("2")},
+				))
+				assert.Equal(t, 2, l1.Len())
+				clock.Advance(time.Second)
+
+				values, err := l2.MGet(ctx, "a", "b")
+				assert.NoError(t, err)
+				assert.Equal(t, [][]byte{[]byte("1"), []byte("2")}, values)
+				values, err = l1.MGet(ctx, "a", "b")
+				assert.NoError(t, err)
+				assert.Equal(t, [][]byte{nil, nil}, values)
+			},
+		},
+		{
+			desc: "delete removes the key from every tier",
+			run: func(t *testing.T, b *TieredBackend, l1, l2 *MemoryBackend, clock *fakeClock) {
+				assert.NoError(t, b.Set(ctx, "a", []byte("1"), 0))
+				assert.NoError(t, b.Delete(ctx, "a"))
+
+				_, err := b.Get(ctx, "a")
+				assert.ErrorIs(t, err, MissError)
+				assert.Equal(t, 0, l1.Len())
+				assert.Equal(t, 0, l2.Len())
+			},
+		},
+		{
+			desc: "incr only uses the last tier",
+			run: func(t *testing.T, b *TieredBackend, l1, l2 *MemoryBackend, clock *fakeClock) {
+				n, err := b.Incr(ctx, "n")
+				assert.NoError(t, err)
+				assert.Equal(t, int64(1), n)
+				assert.Equal(t, 0, l1.Len())
+				assert.Equal(t, 1, l2.Len())
+			},
+		},
+		{
+			desc: "incr drops copies of the counter from the upper tiers",
+			run: func(t *testing.T, b *TieredBackend, l1, l2 *MemoryBackend, clock *fakeClock) {
+				_, err := b.Incr(ctx, "n")
+				assert.NoError(t, err)
+				_, err = b.Get(ctx, "n")
+				assert.NoError(t, err)
+				assert.Equal(t, 1, l1.Len())
+
+				_, err = b.Incr(ctx, "n")
+				assert.NoError(t, err)
+				value, err := b.Get(ctx, "n")
+				assert.NoError(t, err)
+				assert.Equal(t, []byte("2"), value)
+			},
+		},
+		{
+			desc: "leased batches are checked in the last tier and copied up",
+			run: func(t *testing.T, b *TieredBackend, l1, l2 *MemoryBackend, clock *fakeClock) {
+				granted, err := b.MLease(ctx,
+					KeyValue{Key: "lease:a", Value: []byte("t"), TTL: time.Minute},
+					KeyValue{Key: "lease:b", Value: []byte("t"), TTL: time.Minute},
+				)
+				assert.NoError(t, err)
+				assert.Equal(t, []bool{true, true}, granted)
+				assert.Equal(t, 0, l1.Len())
+
+				set, err := b.MSetLeased(ctx,
+					LeasedKeyValue{KeyValue: KeyValue{Key: "a", Value: []byte("1")}, LeaseKey: "lease:a", Token: "t"},
+					LeasedKeyValue{KeyValue: KeyValue{Key: "b", Value: []byte("2")}, LeaseKey: "lease:b", Token: "other"},
+				)
+				assert.NoError(t, err)
+				assert.Equal(t, []bool{true, false}, set)
+
+				values, err := l1.MGet(ctx, "a", "b")
+				assert.NoError(t, err)
+				assert.Equal(t, [][]byte{[]byte("1"), nil}, values)
+				values, err = l2.MGet(ctx, "a", "b", "lease:a", "lease:b")
+				assert.NoError(t, err)
+				assert.Equal(t, [][]byte{[]byte("1"), nil, nil, []byte("t")}, values)
+			},
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.desc, func(t *testing.T) {
+			b, l1, l2, clock := newTestTieredBackend()
+			tc.run(t, b, l1, l2, clock)
+		})
+	}
+}
@@ -0,0 +1,219 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+	"math/rand/v2"
+	"time"
+)
+
+var _ Backend = (*policyBackend)(nil)
+
+var TimeoutError = errors.New("cache backend call timed out")
+
+// wrap returns backend with the timeouts and retries of WithTimeouts and
+// WithRetries applied, or backend itself if neither is set.
+func (o *cacheOptions) wrap(backend Backend) Backend {
+	if o.readTimeout <= 0 && o.writeTimeout <= 0 && o.retries <= 0 {
+		return backend
+	}
+	return &policyBackend{
+		backend: backend,
+		opts:    o,
+	}
+}
+
+// policyBackend bounds each call to the backend of a Cache by its timeout and
+// retries the idempotent ones.
+type policyBackend struct {
+	backend Backend
+	opts    *cacheOptions
+}
+
+// call runs fn under timeout, retrying it if retry is set, and reports whether
+// it ran out of time, in which case err wraps TimeoutError. A call stopped by
+// the caller's own context is a failure, not a timeout. CircuitOpenError is
+// not retried, and a CircuitBreakerBackend beneath counts a retried call's
+// failures once.
+func (b *policyBackend) call(
+	ctx context.Context,
+	operation string,
+	timeout time.Duration,
+	retry bool,
+	fn func(ctx context.Context) error,
+) (timedOut bool, err error) {
+	callCtx := ctx
+	if timeout > 0 {
+		var cancel context.CancelFunc
+		callCtx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+	if retry && b.opts.retries > 0 {
+		callCtx = context.WithValue(callCtx, retriedCallKey{}, &retriedCall{})
+	}
+
+	backoff := b.opts.retryBackoff
+	for attempt := 0; ; attempt++ {
+		err = fn(callCtx)
+		if err == nil || errors.Is(err, MissError) {
+			return false, err
+		}
+		if errors.Is(err, CircuitOpenError) {
+			// The breaker rejects the call without trying the backend, and
+			// keeps doing so until its open timeout has passed.
+			return false, err
+		}
+		if ctx.Err() != nil {
+			return false, err
+		}
+		if callCtx.Err() != nil {
+			return true, b.timedOut(ctx, operation, err)
+		}
+		if !retry || attempt >= b.opts.retries {
+			return false, err
+		}
+
+		if backoff > 0 {
+			timer := time.NewTimer(rand.N(backoff))
+			select {
+			case <-timer.C:
+			case <-callCtx.Done():
+				timer.Stop()
+				if ctx.Err() != nil {
+					return false, err
+				}
+				return true, b.timedOut(ctx, operation, err)
+			}
+			backoff *= 2
+		}
+	}
+}
+
+// timedOut records a timeout of operation and returns err, the error of its
+// last attempt, as a TimeoutError.
+func (b *policyBackend) timedOut(ctx context.Context, operation string, err error) error {
+	b.opts.metrics.add(&timeoutsDesc, b.opts.namespace, operation, 1)
+	trace.SpanFromContext(ctx).AddEvent("cache.timeout", trace.WithAttributes(
+		attribute.String("cache.backend.operation", operation),
+	))
+	return fmt.Errorf("%w: %s: %w", TimeoutError, operation, err)
+}
+
+func (b *policyBackend) Get(ctx context.Context, key string) ([]byte, error) {
+	var value []byte
+	timedOut, err := b.call(ctx, "get", b.opts.readTimeout, true, func(ctx context.Context) error {
+		var err error
+		value, err = b.backend.Get(ctx, key)
+		return err
+	})
+	if timedOut {
+		return nil, MissError
+	}
+	return value, err
+}
+
+// MGet fails with TimeoutError rather than returning nil values when it times
+// out, since a nil tag version reads as a tag that was never invalidated.
+func (b *policyBackend) MGet(ctx context.Context, keys ...string) ([][]byte, error) {
+	var values [][]byte
+	_, err := b.call(ctx, "mget", b.opts.readTimeout, true, func(ctx context.Context) error {
+		var err error
+		values, err = b.backend.MGet(ctx, keys...)
+		return err
+	})
+	return values, err
+}
+
+func (b *policyBackend) Set(ctx context.Context, key string, value []byte, ttl time.Duration) error {
+	_, err := b.call(ctx, "set", b.opts.writeTimeout, true, func(ctx context.Context) error {
+		return b.backend.Set(ctx, key, value, ttl)
+	})
+	return err
+}
+
+func (b *policyBackend) MSet(ctx context.Context, items ...KeyValue) error {
+	_, err := b.call(ctx, "mset", b.opts.writeTimeout, true, func(ctx context.Context) error {
+		return b.backend.MSet(ctx, items...)
+	})
+	return err
+}
+
+func (b *policyBackend) Delete(ctx context.Context, keys ...string) error {
+	_, err := b.call(ctx, "delete", b.opts.writeTimeout, true, func(ctx context.Context) error {
+		return b.backend.Delete(ctx, keys...)
+	})
+	return err
+}
+
+func (b *policyBackend) Expire(ctx context.Context, key string, ttl time.Duration) error {
+	_, err := b.call(ctx, "expire", b.opts.writeTimeout, true, func(ctx context.Context) error {
+		return b.backend.Expire(ctx, key, ttl)
+	})
+	return err
+}
+
+// Incr is not retried, as a retry after a lost reply would count twice.
+func (b *policyBackend) Incr(ctx context.Context, key string) (int64, error) {
+	var n int64
+	_, err := b.call(ctx, "incr", b.opts.writeTimeout, false, func(ctx context.Context) error {
+		var err error
+		n, err = b.backend.Incr(ctx, key)
+		return err
+	})
+	return n, err
+}
+
+// Lease is not retried, as a retry after a lost reply would find the lease
+// already taken, by this very call.
+func (b *policyBackend) Lease(ctx context.Context, leaseKey string, token string, ttl time.Duration) (bool, error) {
+	var granted bool
+	_, err := b.call(ctx, "lease", b.opts.writeTimeout, false, func(ctx context.Context) error {
+		var err error
+		granted, err = b.backend.Lease(ctx, leaseKey, token, ttl)
+		return err
+	})
+	return granted, err
+}
+
+// SetLeased is not retried, as a successful call releases the lease it checks.
+func (b *policyBackend) SetLeased(
+	ctx context.Context,
+	key string,
+	leaseKey string,
+	token string,
+	value []byte,
+	ttl time.Duration,
+) (bool, error) {
+	var set bool
+	_, err := b.call(ctx, "set_leased", b.opts.writeTimeout, false, func(ctx context.Context) error {
+		var err error
+		set, err = b.backend.SetLeased(ctx, key, leaseKey, token, value, ttl)
+		return err
+	})
+	return set, err
+}
+
+// MLease is not retried, for the same reason as Lease.
+func (b *policyBackend) MLease(ctx context.Context, leases ...KeyValue) ([]bool, error) {
+	var granted []bool
+	_, err := b.call(ctx, "mlease", b.opts.writeTimeout, false, func(ctx context.Context) error {
+		var err error
+		granted, err = b.backend.MLease(ctx, leases...)
+		return err
+	})
+	return granted, err
+}
+
+// MSetLeased is not retried, for the same reason as SetLeased.
+func (b *policyBackend) MSetLeased(ctx context.Context, items ...LeasedKeyValue) ([]bool, error) {
+	var set []bool
+	_, err := b.call(ctx, "mset_leased", b.opts.writeTimeout, false, func(ctx context.Context) error {
+		var err error
+		set, err = b.backend.MSetLeased(ctx, items...)
+		return err
+	})
+	return set, err
+}
@@ -0,0 +1,190 @@
+package cache
+
+import (
+	"context"
+	"github.com/stretchr/testify/assert"
+	"strings"
+	"testing"
+	"time"
+)
+
+// slowBackend takes delay to answer calls for keys starting with slowKeys, and
+// fails the first failures calls.
+type slowBackend struct {
+	Backend
+	delay    time.Duration
+	slowKeys string
+	failures int
+	calls    int
+}
+
+func (b *slowBackend) wait(ctx context.Context, key string) error {
+	b.calls++
+	if strings.HasPrefix(key, b.slowKeys) {
+		select {
+		case <-time.After(b.delay):
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+	if b.failures > 0 {
+		b.failures--
+		return errBackendDown
+	}
+	return nil
+}
+
+func (b *slowBackend) Get(ctx context.Context, key string) ([]byte, error) {
+	if err := b.wait(ctx, key); err != nil {
+		return nil, err
+	}
+	return b.Backend.Get(ctx, key)
+}
+
+func (b *slowBackend) MGet(ctx context.Context, keys ...string) ([][]byte, error) {
+	if err := b.wait(ctx, keys[0]); err != nil {
+		return nil, err
+	}
+	return b.Backend.MGet(ctx, keys...)
+}
+
+func (b *slowBackend) Set(ctx context.Context, key string, value []byte, ttl time.Duration) error {
+	if err := b.wait(ctx, key); err != nil {
+		return err
+	}
+	return b.Backend.Set(ctx, key, value, ttl)
+}
+
+func (b *slowBackend) Incr(ctx context.Context, key string) (int64, error) {
+	if err := b.wait(ctx, key); err != nil {
+		return 0, err
+	}
+	return b.Backend.Incr(ctx, key)
+}
+
+func TestCache_Timeouts(t *testing.T) {
+	ctx := context.Background()
+
+	tests := []struct {
+		desc string
+		run  func(t *testing.T, b *slowBackend, metrics *Metrics)
+	}{
+		{
+			desc: "a read that times out is a miss",
+			run: func(t *testing.T, b *slowBackend, metrics *Metrics) {
+				c := New[testItem](b, WithNamespace("item", 1), WithMetrics(metrics), WithTimeouts(time.Millisecond, 0))
+				assert.NoError(t, b.Backend.Set(ctx, c.key("a"), []byte(`{"Name":"a"}`), 0))
+				b.delay = time.Second
+
+				start := time.Now()
+				result, err := c.ReadItem(ctx, "a")
+				assert.NoError(t, err)
+				assert.False(t, result.CacheHit)
+				assert.Less(t, time.Since(start), b.delay)
+
+				results, err := c.ReadItems(ctx, "a", "b")
+				assert.NoError(t, err)
+				assert.False(t, results["a"].CacheHit)
+
+				var out strings.Builder
+				assert.NoError(t, metrics.WritePrometheus(&out))
+				assert.Contains(t, out.String(), `cache_timeouts_total{namespace="item",operation="get"} 1`)
+				assert.Contains(t, out.String(), `cache_timeouts_total{namespace="item",operation="mget"} 1`)
+			},
+		},
+		{
+			desc: "a write that times out fails",
+			run: func(t *testing.T, b *slowBackend, metrics *Metrics) {
+				c := New[testItem](b, WithTimeouts(0, time.Millisecond))
+				b.delay = time.Second
+
+				err := c.WriteItem(ctx, "a", &testItem{Name: "a"})
+				assert.ErrorIs(t, err, TimeoutError)
+				assert.ErrorIs(t, err, context.DeadlineExceeded)
+			},
+		},
+		{
+			desc: "an entry whose tag versions time out is a miss",
+			run: func(t *testing.T, b *slowBackend, metrics *Metrics) {
+				c := New[testItem](b, WithTimeouts(50*time.Millisecond, 0))
+				assert.NoError(t, c.WriteItem(ctx, "a", &testItem{Name: "a"}, WithTags("user:1")))
+				assert.NoError(t, c.InvalidateTag(ctx, "user:1"))
+
+				// Only the tag versions are slow: Get and the first MGet of
+				// ReadItems answer right away.
+				b.delay = time.Second
+				b.slowKeys = "tag:"
+				result, err := c.ReadItem(ctx, "a")
+				assert.NoError(t, err)
+				assert.False(t, result.CacheHit)
+
+				results, err := c.ReadItems(ctx, "a")
+				assert.NoError(t, err)
+				assert.False(t, results["a"].CacheHit)
+			},
+		},
+		{
+			desc: "the caller's deadline is an error rather than a miss",
+			run: func(t *testing.T, b *slowBackend, metrics *Metrics) {
+				c := New[testItem](b, WithTimeouts(time.Second, 0))
+				b.delay = time.Second
+
+				callerCtx, cancel := context.WithTimeout(ctx, time.Millisecond)
+				defer cancel()
+				_, err := c.ReadItem(callerCtx, "a")
+				assert.ErrorIs(t, err, context.DeadlineExceeded)
+			},
+		},
+		{
+			desc: "failed reads and writes are retried",
+			run: func(t *testing.T, b *slowBackend, metrics *Metrics) {
+				c := New[testItem](b, WithRetries(2, time.Millisecond))
+				b.failures = 2
+				assert.NoError(t, c.WriteItem(ctx, "a", &testItem{Name: "a"}))
+				assert.Equal(t, 3, b.calls)
+
+				b.failures = 2
+				result, err := c.ReadItem(ctx, "a")
+				assert.NoError(t, err)
+				assert.True(t, result.CacheHit)
+			},
+		},
+		{
+			desc: "retries are bounded",
+			run: func(t *testing.T, b *slowBackend, metrics *Metrics) {
+				c := New[testItem](b, WithRetries(2, time.Millisecond))
+				b.failures = 3
+				err := c.WriteItem(ctx, "a", &testItem{Name: "a"})
+				assert.ErrorIs(t, err, errBackendDown)
+				assert.Equal(t, 3, b.calls)
+			},
+		},
+		{
+			desc: "incr is never retried",
+			run: func(t *testing.T, b *slowBackend, metrics *Metrics) {
+				c := New[testItem](b, WithRetries(2, time.Millisecond))
+				b.failures = 1
+				assert.ErrorIs(t, c.InvalidateTag(ctx, "user:1"), errBackendDown)
+				assert.Equal(t, 1, b.calls)
+			},
+		},
+		{
+			desc: "retries stop at the timeout",
+			run: func(t *testing.T, b *slowBackend, metrics *Metrics) {
+				c := New[testItem](b, WithTimeouts(time.Millisecond, 0), WithRetries(100, time.Second))
+				b.failures = 100
+
+				result, err := c.ReadItem(ctx, "a")
+				assert.NoError(t, err)
+				assert.False(t, result.CacheHit)
+				assert.Equal(t, 1, b.calls)
+			},
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.desc, func(t *testing.T) {
+			tc.run(t, &slowBackend{Backend: NewMemoryBackend()}, NewMetrics())
+		})
+	}
+}
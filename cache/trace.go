@@ -0,0 +1,43 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+	"time"
+)
+
+// tracer returns the package's tracer. It is looked up on every use rather
+// than once, so that spans go to whichever provider is installed at the time.
+func tracer() trace.Tracer {
+	return otel.Tracer("github.com/anmho/caching/cache")
+}
+
+// begin starts a span for operation and returns the function that ends it,
+// recording the operation in the cache's metrics.
+func (c *Cache[T]) begin(ctx context.Context, operation string, attrs ...attribute.KeyValue) (context.Context, func(err *error)) {
+	start := time.Now()
+	ctx, span := tracer().Start(ctx, "cache."+operation, trace.WithAttributes(
+		append(attrs, attribute.String("cache.namespace", c.opts.namespace))...,
+	))
+	return ctx, func(err *error) {
+		c.record(operation, start, err)
+		if *err != nil && !errors.Is(*err, LeaseLostError) && !errors.Is(*err, NotFoundError) {
+			span.RecordError(*err)
+			span.SetStatus(codes.Error, (*err).Error())
+		}
+		span.End()
+	}
+}
+
+// traceRead adds the outcome of a read to the span in ctx.
+func traceRead[T any](ctx context.Context, result ReadCacheResult[T]) {
+	trace.SpanFromContext(ctx).SetAttributes(
+		attribute.Bool("cache.hit", result.CacheHit),
+		attribute.Bool("cache.stale", result.Stale),
+		attribute.Bool("cache.not_found", result.NotFound),
+	)
+}
@@ -0,0 +1,64 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"testing"
+)
+
+func TestCache_Tracing(t *testing.T) {
+	recorder := tracetest.NewSpanRecorder()
+	provider := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder))
+	previous := otel.GetTracerProvider()
+	otel.SetTracerProvider(provider)
+	t.Cleanup(func() { otel.SetTracerProvider(previous) })
+
+	ctx := context.Background()
+	c := New[testItem](NewMemoryBackend(), WithNamespace("item", 1))
+
+	assert.NoError(t, c.WriteItem(ctx, "a", &testItem{Name: "a"}))
+	_, err := c.ReadItem(ctx, "a")
+	assert.NoError(t, err)
+	_, err = c.Fetch(ctx, "b", func(ctx context.Context, key string) (*testItem, error) {
+		return nil, errors.New("source unavailable")
+	})
+	assert.Error(t, err)
+
+	spans := make(map[string][]sdktrace.ReadOnlySpan)
+	for _, span := range recorder.Ended() {
+		spans[span.Name()] = append(spans[span.Name()], span)
+	}
+
+	tests := []struct {
+		desc     string
+		name     string
+		attr     attribute.KeyValue
+		expected codes.Code
+	}{
+		{desc: "write", name: "cache.write", attr: attribute.String("cache.namespace", "item"), expected: codes.Unset},
+		{desc: "hit", name: "cache.read", attr: attribute.Bool("cache.hit", true), expected: codes.Unset},
+		{desc: "miss", name: "cache.read", attr: attribute.Bool("cache.hit", false), expected: codes.Unset},
+		{desc: "failed load", name: "cache.load", attr: attribute.String("cache.key", "b"), expected: codes.Error},
+	}
+	for _, tc := range tests {
+		t.Run(tc.desc, func(t *testing.T) {
+			var matched sdktrace.ReadOnlySpan
+			for _, span := range spans[tc.name] {
+				for _, attr := range span.Attributes() {
+					if attr == tc.attr {
+						matched = span
+					}
+				}
+			}
+			if assert.NotNil(t, matched) {
+				assert.Equal(t, tc.expected, matched.Status().Code)
+			}
+		})
+	}
+}
@@ -0,0 +1,334 @@
+package cache
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"sync"
+	"time"
+)
+
+var (
+	WriteBehindStoppedError = errors.New("write behind queue is stopped")
+	NoJournalError          = errors.New("write behind queue has no journal")
+	JournalDecodeError      = errors.New("write behind journal entry does not decode")
+)
+
+// FlushFunc persists a batch of dirty items, keyed by cache key, to the source
+// of truth. A batch holds at most the configured max batch size.
+type FlushFunc[T any] func(ctx context.Context, items map[string]*T) error
+
+type writeBehindOptions struct {
+	maxBatchSize  int
+	flushInterval time.Duration
+	flushTimeout  time.Duration
+}
+
+type WriteBehindOpt func(o *writeBehindOptions)
+
+// WithMaxBatchSize sets how many dirty items trigger an early flush and how
+// many items are handed to a single FlushFunc call.
+func WithMaxBatchSize(size int) WriteBehindOpt {
+	return func(o *writeBehindOptions) {
+		o.maxBatchSize = size
+	}
+}
+
+// WithFlushInterval sets the longest a dirty item waits before being flushed.
+func WithFlushInterval(interval time.Duration) WriteBehindOpt {
+	return func(o *writeBehindOptions) {
+		o.flushInterval = interval
+	}
+}
+
+// WithFlushTimeout bounds each background flush.
+func WithFlushTimeout(timeout time.Duration) WriteBehindOpt {
+	return func(o *writeBehindOptions) {
+		o.flushTimeout = timeout
+	}
+}
+
+// WriteBehind accepts writes into the cache and flushes them to the source of
+// truth in batches from a background goroutine. Every write is recorded in a
+// Journal before it is acknowledged, and the journal is replayed by Start.
+//
+// Journaled values are JSON, independently of the cache's codec, namespace and
+// keyring, so that changing any of them does not strand queued writes. They
+// are not encrypted.
+type WriteBehind[T any] struct {
+	cache   *Cache[T]
+	journal Journal
+	flush   FlushFunc[T]
+	opts    writeBehindOptions
+
+	// mu guards pending and keeps it in step with the journal.
+	mu      sync.Mutex
+	pending map[string]*T
+	// flushMu serializes flushes from the background loop and from callers.
+	flushMu sync.Mutex
+
+	started bool
+	kick    chan struct{}
+	done    chan struct{}
+	stopped chan struct{}
+	stop    sync.Once
+}
+
+func NewWriteBehind[T any](
+	cache *Cache[T],
+	journal Journal,
+	flush FlushFunc[T],
+	opts ...WriteBehindOpt,
+) *WriteBehind[T] {
+	o := writeBehindOptions{
+		maxBatchSize:  25,
+		flushInterval: time.Second,
+		flushTimeout:  10 * time.Second,
+	}
+	for _, opt := range opts {
+		opt(&o)
+	}
+
+	return &WriteBehind[T]{
+		cache:   cache,
+		journal: journal,
+		flush:   flush,
+		opts:    o,
+		pending: make(map[string]*T),
+		kick:    make(chan struct{}, 1),
+		done:    make(chan struct{}),
+		stopped: make(chan struct{}),
+	}
+}
+
+// Start replays any writes left in the journal by a previous run of this
+// process and starts the background flusher. The journal holds the only copy
+// of acknowledged writes, so if any entry does not decode Start fails with
+// JournalDecodeError and leaves the journal as it is, for an operator to fix.
+func (w *WriteBehind[T]) Start(ctx context.Context) error {
+	if w.journal == nil {
+		return NoJournalError
+	}
+	entries, err := w.journal.Load(ctx)
+	if err != nil {
+		return err
+	}
+
+	w.mu.Lock()
+	select {
+	case <-w.done:
+		w.mu.Unlock()
+		return WriteBehindStoppedError
+	default:
+	}
+	recovered := make(map[string]*T, len(entries))
+	for key, b := range entries {
+		if _, ok := w.pending[key]; ok {
+			continue
+		}
+		data := new(T)
+		err := w.decodeJournaled(key, b, data)
+		if err != nil {
+			w.mu.Unlock()
+			return fmt.Errorf("%w: %q: %w", JournalDecodeError, key, err)
+		}
+		recovered[key] = data
+	}
+	for key, data := range recovered {
+		w.pending[key] = data
+	}
+	w.started = true
+	w.mu.Unlock()
+
+	if len(recovered) > 0 {
+		slog.Info("write behind recovered journal", slog.Int("pending", len(recovered)))
+		w.signal()
+	}
+
+	go w.run()
+	return nil
+}
+
+// Write queues data for key. It returns once the write is journaled, before the
+// source of truth has been updated. opts apply to the write to the cache.
+func (w *WriteBehind[T]) Write(ctx context.Context, key string, data *T, opts ...WriteOpt) error {
+	select {
+	case <-w.done:
+		return WriteBehindStoppedError
+	default:
+	}
+	if w.journal == nil {
+		return NoJournalError
+	}
+
+	b, err := json.Marshal(data)
+	if err != nil {
+		return err
+	}
+
+	w.mu.Lock()
+	err = w.journal.Record(ctx, key, b)
+	if err != nil {
+		w.mu.Unlock()
+		return err
+	}
+	w.pending[key] = data
+	size := len(w.pending)
+	w.mu.Unlock()
+
+	// The journal already holds the write, so a failed cache write only means
+	// the next read will miss.
+	err = w.cache.WriteItem(ctx, key, data, opts...)
+	if err != nil {
+		slog.Error("write behind cache write",
+			slog.Any("error", err),
+			slog.String("key", key),
+		)
+		_ = w.cache.InvalidateKey(ctx, key)
+	}
+
+	if size >= w.opts.maxBatchSize {
+		w.signal()
+	}
+	return nil
+}
+
+// Pending returns the queued value for key, if it has not been flushed yet.
+func (w *WriteBehind[T]) Pending(key string) (*T, bool) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	data, ok := w.pending[key]
+	return data, ok
+}
+
+// PendingItems returns a snapshot of every queued value.
+func (w *WriteBehind[T]) PendingItems() map[string]*T {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	items := make(map[string]*T, len(w.pending))
+	for key, data := range w.pending {
+		items[key] = data
+	}
+	return items
+}
+
+// Flush writes every item queued so far to the source of truth. Items that
+// fail stay queued and are retried by the next flush.
+func (w *WriteBehind[T]) Flush(ctx context.Context) error {
+	w.flushMu.Lock()
+	defer w.flushMu.Unlock()
+
+	snapshot := w.PendingItems()
+	batch := make(map[string]*T, w.opts.maxBatchSize)
+	for key, data := range snapshot {
+		batch[key] = data
+		if len(batch) < w.opts.maxBatchSize {
+			continue
+		}
+		err := w.flushBatch(ctx, batch)
+		if err != nil {
+			return err
+		}
+		batch = make(map[string]*T, w.opts.maxBatchSize)
+	}
+	if len(batch) > 0 {
+		return w.flushBatch(ctx, batch)
+	}
+	return nil
+}
+
+// FlushKey writes the item queued for key, if any, to the source of truth,
+// leaving the rest of the queue to the next flush.
+func (w *WriteBehind[T]) FlushKey(ctx context.Context, key string) error {
+	w.flushMu.Lock()
+	defer w.flushMu.Unlock()
+
+	data, ok := w.Pending(key)
+	if !ok {
+		return nil
+	}
+	return w.flushBatch(ctx, map[string]*T{key: data})
+}
+
+// Drain stops the background flusher and flushes everything still queued. It
+// should be called on shutdown; writes made after Drain are rejected.
+func (w *WriteBehind[T]) Drain(ctx context.Context) error {
+	w.stop.Do(func() {
+		close(w.done)
+
+		w.mu.Lock()
+		defer w.mu.Unlock()
+		if !w.started {
+			close(w.stopped)
+		}
+	})
+
+	select {
+	case <-w.stopped:
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+
+	return w.Flush(ctx)
+}
+
+func (w *WriteBehind[T]) flushBatch(ctx context.Context, batch map[string]*T) error {
+	err := w.flush(ctx, batch)
+	if err != nil {
+		return err
+	}
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	// Keys written again while the batch was in flight stay dirty.
+	flushed := make([]string, 0, len(batch))
+	for key, data := range batch {
+		if w.pending[key] == data {
+			delete(w.pending, key)
+			flushed = append(flushed, key)
+		}
+	}
+	return w.journal.Remove(ctx, flushed...)
+}
+
+// decodeJournaled decodes a journal entry. Entries journaled before values were
+// JSON were encoded like the cache's values.
+func (w *WriteBehind[T]) decodeJournaled(key string, b []byte, data *T) error {
+	if len(b) > 0 && b[0] == '{' {
+		return json.Unmarshal(b, data)
+	}
+	return w.cache.unmarshal(w.cache.key(key), b, data)
+}
+
+func (w *WriteBehind[T]) signal() {
+	select {
+	case w.kick <- struct{}{}:
+	default:
+	}
+}
+
+func (w *WriteBehind[T]) run() {
+	defer close(w.stopped)
+
+	ticker := time.NewTicker(w.opts.flushInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+		case <-w.kick:
+		case <-w.done:
+			return
+		}
+
+		ctx, cancel := context.WithTimeout(context.Background(), w.opts.flushTimeout)
+		err := w.Flush(ctx)
+		cancel()
+		if err != nil {
+			slog.Error("write behind flush", slog.Any("error", err))
+		}
+	}
+}
@@ -0,0 +1,266 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"sync"
+	"testing"
+	"time"
+)
+
+// flushRecorder is a FlushFunc recording the batches it is handed. When gate
+// is set, flushes signal started and wait for gate to be closed.
+type flushRecorder struct {
+	mu      sync.Mutex
+	batches []map[string]testItem
+	err     error
+
+	started chan struct{}
+	gate    chan struct{}
+}
+
+func (f *flushRecorder) flush(ctx context.Context, items map[string]*testItem) error {
+	if f.gate != nil {
+		select {
+		case f.started <- struct{}{}:
+		default:
+		}
+		<-f.gate
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.err != nil {
+		return f.err
+	}
+	batch := make(map[string]testItem, len(items))
+	for key, item := range items {
+		batch[key] = *item
+	}
+	f.batches = append(f.batches, batch)
+	return nil
+}
+
+// flushed merges every batch, later batches winning.
+func (f *flushRecorder) flushed() map[string]testItem {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	items := make(map[string]testItem)
+	for _, batch := range f.batches {
+		for key, item := range batch {
+			items[key] = item
+		}
+	}
+	return items
+}
+
+func TestWriteBehind(t *testing.T) {
+	ctx := context.Background()
+
+	tests := []struct {
+		desc string
+		run  func(t *testing.T, c *Cache[testItem], journal *MemoryJournal, f *flushRecorder)
+	}{
+		{
+			desc: "writes are cached and journaled until flushed",
+			run: func(t *testing.T, c *Cache[testItem], journal *MemoryJournal, f *flushRecorder) {
+				w := NewWriteBehind(c, journal, f.flush, WithFlushInterval(time.Hour))
+				require.NoError(t, w.Start(ctx))
+				require.NoError(t, w.Write(ctx, "a", &testItem{Name: "a"}))
+
+				result, err := c.ReadItem(ctx, "a")
+				require.NoError(t, err)
+				assert.True(t, result.CacheHit)
+				entries, _ := journal.Load(ctx)
+				assert.Contains(t, entries, "a")
+
+				require.NoError(t, w.Flush(ctx))
+				assert.Equal(t, map[string]testItem{"a": {Name: "a"}}, f.flushed())
+				entries, _ = journal.Load(ctx)
+				assert.Empty(t, entries)
+				_, ok := w.Pending("a")
+				assert.False(t, ok)
+			},
+		},
+		{
+			desc: "start replays the journal",
+			run: func(t *testing.T, c *Cache[testItem], journal *MemoryJournal, f *flushRecorder) {
+				require.NoError(t, journal.Record(ctx, "a", []byte(`{"Name":"a"}`)))
+				// Entries journaled before values were JSON are cache values.
+				b, err := c.marshal(c.key("b"), &testItem{Name: "b"})
+				require.NoError(t, err)
+				require.NoError(t, journal.Record(ctx, "b", b))
+
+				w := NewWriteBehind(c, journal, f.flush, WithFlushInterval(time.Hour))
+				require.NoError(t, w.Start(ctx))
+				pending, ok := w.Pending("a")
+				require.True(t, ok)
+				assert.Equal(t, "a", pending.Name)
+
+				require.NoError(t, w.Drain(ctx))
+				assert.Equal(t, map[string]testItem{"a": {Name: "a"}, "b": {Name: "b"}}, f.flushed())
+				entries, _ := journal.Load(ctx)
+				assert.Empty(t, entries)
+			},
+		},
+		{
+			desc: "start fails on entries that do not decode and keeps them",
+			run: func(t *testing.T, c *Cache[testItem], journal *MemoryJournal, f *flushRecorder) {
+				require.NoError(t, journal.Record(ctx, "a", []byte(`{"Name":"a"}`)))
+				require.NoError(t, journal.Record(ctx, "corrupt", []byte{0xff}))
+
+				w := NewWriteBehind(c, journal, f.flush, WithFlushInterval(time.Hour))
+				assert.ErrorIs(t, w.Start(ctx), JournalDecodeError)
+				entries, _ := journal.Load(ctx)
+				assert.Len(t, entries, 2)
+			},
+		},
+		{
+			desc: "queued writes survive a change of namespace and keyring",
+			run: func(t *testing.T, c *Cache[testItem], journal *MemoryJournal, f *flushRecorder) {
+				before := New[testItem](NewMemoryBackend(),
+					WithNamespace("item", 1),
+					WithEncryption(newTestKeyring(t, "k1", "k1")),
+				)
+				w := NewWriteBehind(before, journal, f.flush, WithFlushInterval(time.Hour))
+				require.NoError(t, w.Write(ctx, "a", &testItem{Name: "a"}))
+
+				after := New[testItem](NewMemoryBackend(),
+					WithNamespace("item", 2),
+					WithEncryption(newTestKeyring(t, "k2", "k2")),
+				)
+				w = NewWriteBehind(after, journal, f.flush, WithFlushInterval(time.Hour))
+				require.NoError(t, w.Start(ctx))
+				require.NoError(t, w.Drain(ctx))
+				assert.Equal(t, map[string]testItem{"a": {Name: "a"}}, f.flushed())
+			},
+		},
+		{
+			desc: "a queue without a journal fails",
+			run: func(t *testing.T, c *Cache[testItem], journal *MemoryJournal, f *flushRecorder) {
+				w := NewWriteBehind(c, nil, f.flush)
+				assert.ErrorIs(t, w.Write(ctx, "a", &testItem{Name: "a"}), NoJournalError)
+				assert.ErrorIs(t, w.Start(ctx), NoJournalError)
+			},
+		},
+		{
+			desc: "a key rewritten during a flush stays queued with the newer value",
+			run: func(t *testing.T, c *Cache[testItem], journal *MemoryJournal, f *flushRecorder) {
+				w := NewWriteBehind(c, journal, f.flush, WithFlushInterval(time.Hour))
+				require.NoError(t, w.Start(ctx))
+				require.NoError(t, w.Write(ctx, "a", &testItem{Name: "v1"}))
+
+				f.started = make(chan struct{}, 1)
+				f.gate = make(chan struct{})
+				flushed := make(chan error)
+				go func() {
+					flushed <- w.Flush(ctx)
+				}()
+				<-f.started
+				require.NoError(t, w.Write(ctx, "a", &testItem{Name: "v2"}))
+				close(f.gate)
+				require.NoError(t, <-flushed)
+
+				assert.Equal(t, map[string]testItem{"a": {Name: "v1"}}, f.flushed())
+				pending, ok := w.Pending("a")
+				require.True(t, ok)
+				assert.Equal(t, "v2", pending.Name)
+				entries, _ := journal.Load(ctx)
+				assert.Contains(t, entries, "a")
+
+				require.NoError(t, w.Flush(ctx))
+				assert.Equal(t, map[string]testItem{"a": {Name: "v2"}}, f.flushed())
+				entries, _ = journal.Load(ctx)
+				assert.Empty(t, entries)
+			},
+		},
+		{
+			desc: "a failed flush keeps the batch queued",
+			run: func(t *testing.T, c *Cache[testItem], journal *MemoryJournal, f *flushRecorder) {
+				w := NewWriteBehind(c, journal, f.flush, WithFlushInterval(time.Hour))
+				require.NoError(t, w.Start(ctx))
+				require.NoError(t, w.Write(ctx, "a", &testItem{Name: "a"}))
+
+				f.err = errors.New("source unavailable")
+				assert.ErrorIs(t, w.Flush(ctx), f.err)
+				_, ok := w.Pending("a")
+				assert.True(t, ok)
+				entries, _ := journal.Load(ctx)
+				assert.Contains(t, entries, "a")
+
+				f.err = nil
+				require.NoError(t, w.Flush(ctx))
+				assert.Contains(t, f.flushed(), "a")
+			},
+		},
+		{
+			desc: "flushing a key leaves the rest queued",
+			run: func(t *testing.T, c *Cache[testItem], journal *MemoryJournal, f *flushRecorder) {
+				w := NewWriteBehind(c, journal, f.flush, WithFlushInterval(time.Hour))
+				require.NoError(t, w.Start(ctx))
+				require.NoError(t, w.Write(ctx, "a", &testItem{Name: "a"}))
+				require.NoError(t, w.Write(ctx, "b", &testItem{Name: "b"}))
+
+				require.NoError(t, w.FlushKey(ctx, "a"))
+				require.NoError(t, w.FlushKey(ctx, "c"))
+				assert.Equal(t, []map[string]testItem{{"a": {Name: "a"}}}, f.batches)
+				assert.Equal(t, map[string]*testItem{"b": {Name: "b"}}, w.PendingItems())
+				entries, _ := journal.Load(ctx)
+				assert.Len(t, entries, 1)
+				assert.Contains(t, entries, "b")
+			},
+		},
+		{
+			desc: "drain flushes in batches and rejects later writes",
+			run: func(t *testing.T, c *Cache[testItem], journal *MemoryJournal, f *flushRecorder) {
+				w := NewWriteBehind(c, journal, f.flush, WithFlushInterval(time.Hour), WithMaxBatchSize(2))
+				require.NoError(t, w.Start(ctx))
+				for _, key := range []string{"a", "b", "c"} {
+					require.NoError(t, w.Write(ctx, key, &testItem{Name: key}))
+				}
+
+				require.NoError(t, w.Drain(ctx))
+				assert.Len(t, f.flushed(), 3)
+				for _, batch := range f.batches {
+					assert.LessOrEqual(t, len(batch), 2)
+				}
+				assert.Empty(t, w.PendingItems())
+				assert.ErrorIs(t, w.Write(ctx, "d", &testItem{Name: "d"}), WriteBehindStoppedError)
+			},
+		},
+		{
+			desc: "drain before start flushes without waiting for the flusher",
+			run: func(t *testing.T, c *Cache[testItem], journal *MemoryJournal, f *flushRecorder) {
+				w := NewWriteBehind(c, journal, f.flush)
+				require.NoError(t, w.Write(ctx, "a", &testItem{Name: "a"}))
+
+				require.NoError(t, w.Drain(ctx))
+				assert.Contains(t, f.flushed(), "a")
+				assert.ErrorIs(t, w.Start(ctx), WriteBehindStoppedError)
+			},
+		},
+		{
+			desc: "the background flusher flushes once the batch is full",
+			run: func(t *testing.T, c *Cache[testItem], journal *MemoryJournal, f *flushRecorder) {
+				w := NewWriteBehind(c, journal, f.flush, WithFlushInterval(time.Hour), WithMaxBatchSize(2))
+				require.NoError(t, w.Start(ctx))
+				require.NoError(t, w.Write(ctx, "a", &testItem{Name: "a"}))
+				require.NoError(t, w.Write(ctx, "b", &testItem{Name: "b"}))
+
+				assert.Eventually(t, func() bool {
+					return len(w.PendingItems()) == 0
+				}, time.Second, time.Millisecond)
+				assert.Len(t, f.flushed(), 2)
+				require.NoError(t, w.Drain(ctx))
+			},
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.desc, func(t *testing.T) {
+			tc.run(t, New[testItem](NewMemoryBackend()), NewMemoryJournal(), &flushRecorder{})
+		})
+	}
+}
@@ -2,6 +2,7 @@ package main
 
 import (
 	"context"
+	"encoding/base64"
 	"errors"
 	"fmt"
 	"github.com/anmho/caching/api"
@@ -11,9 +12,20 @@ import (
 	"github.com/aws/aws-sdk-go-v2/config"
 	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
 	"github.com/go-redis/redis/v8"
+	"github.com/google/uuid"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/exporters/stdout/stdouttrace"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
 	"log"
 	"net/http"
 	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"time"
 )
 
 const (
@@ -23,6 +35,65 @@ const (
 	RedisPassword = "password"
 
 	DynamoDBURL = "http://localhost:8000"
+
+	// CacheBackendEnv selects the cache backend, "redis" (default) or "memory".
+	CacheBackendEnv = "CACHE_BACKEND"
+	// CacheEncryptionKeysEnv holds the keys cached todos are encrypted with, as
+	// comma separated id=base64 pairs. The first key is the current one, the
+	// others are only used to read entries written before a rotation.
+	CacheEncryptionKeysEnv = "CACHE_ENCRYPTION_KEYS"
+	// TracesExporterEnv selects where spans are exported, "stdout" or "file".
+	// Tracing is off when it is unset.
+	TracesExporterEnv = "TRACES_EXPORTER"
+	// TracesFileEnv is the file the "file" exporter appends spans to in the
+	// OTLP file format, one line of OTLP JSON per batch.
+	TracesFileEnv = "TRACES_FILE"
+
+	serviceName       = "todo-api"
+	defaultTracesFile = "traces.jsonl"
+
+	shutdownTimeout = 30 * time.Second
+
+	todoCacheTTL       = 10 * time.Minute
+	todoCacheTTLJitter = time.Minute
+	// Stale todos are served while they are refreshed, or while DynamoDB is
+	// failing, for a while after their TTL.
+	todoCacheStaleWhileRevalidate = time.Minute
+	todoCacheStaleIfError         = 30 * time.Minute
+	// Lookups of missing todos are cached briefly.
+	todoCacheNegativeTTL = 30 * time.Second
+	// A reader that missed has this long to fill the cache.
+	todoCacheLeaseTTL = 10 * time.Second
+	// Bump the schema versions when Todo or its cache keys change, to stop
+	// reading entries written in the old format.
+	todoCacheNamespace         = "todo"
+	todoCacheSchemaVersion     = 3
+	todoListCacheNamespace     = "todo-list"
+	todoListCacheSchemaVersion = 1
+
+	// Todos with long descriptions are compressed.
+	todoCacheCompressionThreshold = 1024
+
+	// Lists of todo IDs are invalidated on every write to the user's todos.
+	todoListCacheTTL = 5 * time.Minute
+
+	// The in-process tier in front of Redis holds a few hot todos briefly.
+	localCacheTTL        = 5 * time.Second
+	localCacheMaxEntries = 10_000
+
+	// After cacheBreakerFailures consecutive Redis failures the cache is
+	// bypassed, and Redis is probed again every cacheBreakerOpenTimeout.
+	cacheBreakerFailures    = 5
+	cacheBreakerOpenTimeout = 10 * time.Second
+
+	// A cache call may only take a small share of the request budget. Reads
+	// that take longer are misses.
+	cacheReadTimeout  = 20 * time.Millisecond
+	cacheWriteTimeout = 50 * time.Millisecond
+	cacheRetries      = 2
+	cacheRetryBackoff = 2 * time.Millisecond
+
+	todoInvalidationChannel = "todo:invalidations"
 )
 
 func WithEndpoint(endpoint string) func(*dynamodb.Options) {
@@ -31,7 +102,115 @@ func WithEndpoint(endpoint string) func(*dynamodb.Options) {
 	}
 }
 
+// newCacheBackend builds the backend selected by CacheBackendEnv. The Redis
+// backend has an in-process tier in front of it, kept consistent across
+// replicas by an invalidator running until ctx is done. Evictions from the
+// in-process tier are counted in metrics.
+func newCacheBackend(ctx context.Context, redisClient *redis.Client, metrics *cache.Metrics) cache.Backend {
+	if os.Getenv(CacheBackendEnv) == "memory" {
+		return cache.NewMemoryBackend()
+	}
+
+	local := cache.NewMemoryBackend(
+		cache.WithMaxEntries(localCacheMaxEntries),
+		cache.WithEvictionCallback(metrics.RecordEviction),
+	)
+	invalidator := cache.NewInvalidator(redisClient, todoInvalidationChannel, local)
+	go func() {
+		err := invalidator.Run(ctx)
+		if err != nil && !errors.Is(err, context.Canceled) {
+			log.Println("cache invalidator stopped:", err)
+		}
+	}()
+
+	// The breaker wraps the invalidator too, as publishing needs Redis as well.
+	return cache.NewCircuitBreakerBackend(
+		invalidator.Wrap(cache.NewTieredBackend(
+			cache.Tier{Backend: local, TTL: localCacheTTL},
+			cache.Tier{Backend: cache.NewRedisBackend(redisClient)},
+		)),
+		cache.WithFailureThreshold(cacheBreakerFailures),
+		cache.WithOpenTimeout(cacheBreakerOpenTimeout),
+	)
+}
+
+// newCacheKeyring parses CacheEncryptionKeysEnv. It returns nil when the
+// variable is unset.
+func newCacheKeyring() (*cache.Keyring, error) {
+	env := os.Getenv(CacheEncryptionKeysEnv)
+	if env == "" {
+		return nil, nil
+	}
+
+	var current string
+	keys := make(map[string][]byte)
+	for _, pair := range strings.Split(env, ",") {
+		id, encoded, ok := strings.Cut(strings.TrimSpace(pair), "=")
+		if !ok {
+			return nil, fmt.Errorf("%s: expected id=base64, got %q", CacheEncryptionKeysEnv, pair)
+		}
+		key, err := base64.StdEncoding.DecodeString(encoded)
+		if err != nil {
+			return nil, fmt.Errorf("%s: key %q: %w", CacheEncryptionKeysEnv, id, err)
+		}
+		if current == "" {
+			current = id
+		}
+		keys[id] = key
+	}
+	return cache.NewKeyring(current, keys)
+}
+
+// setupTracing installs a tracer provider exporting spans as selected by
+// TracesExporterEnv, and returns the function flushing them on shutdown.
+func setupTracing() (func(ctx context.Context) error, error) {
+	otel.SetTextMapPropagator(propagation.TraceContext{})
+
+	var exporter sdktrace.SpanExporter
+	var file *os.File
+	switch name := os.Getenv(TracesExporterEnv); name {
+	case "":
+		return func(ctx context.Context) error { return nil }, nil
+	case "stdout":
+		var err error
+		exporter, err = stdouttrace.New(stdouttrace.WithWriter(os.Stdout))
+		if err != nil {
+			return nil, err
+		}
+	case "file":
+		path := os.Getenv(TracesFileEnv)
+		if path == "" {
+			path = defaultTracesFile
+		}
+		var err error
+		file, err = os.OpenFile(path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o644)
+		if err != nil {
+			return nil, err
+		}
+		exporter = newOTLPFileExporter(file)
+	default:
+		return nil, fmt.Errorf("%s: unknown exporter %q", TracesExporterEnv, name)
+	}
+
+	provider := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithResource(resource.NewSchemaless(semconv.ServiceName(serviceName))),
+	)
+	otel.SetTracerProvider(provider)
+
+	return func(ctx context.Context) error {
+		err := provider.Shutdown(ctx)
+		if file != nil {
+			err = errors.Join(err, file.Close())
+		}
+		return err
+	}, nil
+}
+
 func main() {
+	runCtx, stopRunning := context.WithCancel(context.Background())
+	defer stopRunning()
+
 	redisClient := redis.NewClient(&redis.Options{
 		Network:  "tcp",
 		Addr:     RedisURL,
@@ -42,26 +221,103 @@ func main() {
 		log.Fatalln(err)
 	}
 
+	shutdownTracing, err := setupTracing()
+	if err != nil {
+		log.Fatalln(err)
+	}
+
 	// Setup dependencies
+	keyring, err := newCacheKeyring()
+	if err != nil {
+		log.Fatalln(err)
+	}
+	metrics := cache.NewMetrics()
+	todoCacheOpts := []cache.CacheOpt{
+		cache.WithNamespace(todoCacheNamespace, todoCacheSchemaVersion),
+		cache.WithMetrics(metrics),
+		// Todos are read on every request, MessagePack is smaller and
+		// decodes faster than JSON.
+		cache.WithCodec(cache.MsgPackCodec{}),
+		cache.WithCompression(todoCacheCompressionThreshold),
+		cache.WithDefaultTTL(todoCacheTTL),
+		cache.WithTTLJitter(todoCacheTTLJitter),
+		cache.WithEarlyRecomputation(1),
+		cache.WithStaleWhileRevalidate(todoCacheStaleWhileRevalidate),
+		cache.WithStaleIfError(todoCacheStaleIfError),
+		cache.WithNegativeTTL(todoCacheNegativeTTL),
+		cache.WithLeases(todoCacheLeaseTTL),
+		cache.WithTimeouts(cacheReadTimeout, cacheWriteTimeout),
+		cache.WithRetries(cacheRetries, cacheRetryBackoff),
+	}
+	if keyring != nil {
+		// Titles and descriptions are user content. Encrypted caches reject
+		// plaintext values, so bump todoCacheSchemaVersion when turning
+		// encryption on.
+		todoCacheOpts = append(todoCacheOpts, cache.WithEncryption(keyring))
+	} else {
+		log.Printf("%s is unset, cached todos are not encrypted\n", CacheEncryptionKeysEnv)
+	}
+
 	dynamoClient := dynamodb.NewFromConfig(cfg, WithEndpoint(DynamoDBURL))
-	todoCache := cache.New[todo.Todo](redisClient)
+	cacheBackend := newCacheBackend(runCtx, redisClient, metrics)
+	todoCache := cache.New[todo.Todo](cacheBackend, todoCacheOpts...)
+	todoListCache := cache.New[[]uuid.UUID](
+		cacheBackend,
+		cache.WithNamespace(todoListCacheNamespace, todoListCacheSchemaVersion),
+		cache.WithMetrics(metrics),
+		cache.WithDefaultTTL(todoListCacheTTL),
+		cache.WithTTLJitter(todoCacheTTLJitter),
+		cache.WithLeases(todoCacheLeaseTTL),
+		cache.WithTimeouts(cacheReadTimeout, cacheWriteTimeout),
+		cache.WithRetries(cacheRetries, cacheRetryBackoff),
+	)
 	todoService := todo.MakeService(
 		dynamoClient,
 		todoCache,
+		todo.WithCacheStrategy(cache.CacheAside),
+		todo.WithListCache(todoListCache),
 	)
 
+	err = todoService.Start(context.Background())
+	if err != nil {
+		log.Fatalln(err)
+	}
+
 	mux := api.New(todoService)
+	mux.Handle("GET /metrics", metrics)
 	srv := http.Server{
 		Addr:    fmt.Sprintf(":%d", port),
 		Handler: mux,
 	}
 
-	log.Printf("listening on port %d\n", port)
-	if err := srv.ListenAndServe(); err != nil {
-		if errors.Is(err, http.ErrServerClosed) {
-			// shutdown
-			log.Println("shutting down mux")
-			os.Exit(0)
+	go func() {
+		log.Printf("listening on port %d\n", port)
+		if err := srv.ListenAndServe(); err != nil {
+			if errors.Is(err, http.ErrServerClosed) {
+				// shutdown
+				log.Println("shutting down mux")
+				return
+			}
+			log.Fatalln(err)
 		}
+	}()
+
+	stop := make(chan os.Signal, 1)
+	signal.Notify(stop, syscall.SIGINT, syscall.SIGTERM)
+	<-stop
+
+	ctx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer cancel()
+
+	if err := srv.Shutdown(ctx); err != nil {
+		log.Println("shutting down server:", err)
+	}
+	// Flush queued write-back writes once no more requests can arrive.
+	if err := todoService.Drain(ctx); err != nil {
+		log.Println("draining todo service:", err)
+		os.Exit(1)
+	}
+	if err := shutdownTracing(ctx); err != nil {
+		log.Println("flushing traces:", err)
 	}
 }
@@ -0,0 +1,266 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/sdk/instrumentation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"io"
+	"strconv"
+	"sync"
+)
+
+// otlpFileExporter writes spans in the OTLP file format: every export is one
+// line holding a TracesData message in the OTLP JSON encoding, which the
+// OpenTelemetry Collector's otlpjsonfile receiver and most trace viewers read.
+type otlpFileExporter struct {
+	mu sync.Mutex
+	w  io.Writer
+}
+
+var _ sdktrace.SpanExporter = (*otlpFileExporter)(nil)
+
+func newOTLPFileExporter(w io.Writer) *otlpFileExporter {
+	return &otlpFileExporter{w: w}
+}
+
+func (e *otlpFileExporter) ExportSpans(ctx context.Context, spans []sdktrace.ReadOnlySpan) error {
+	if len(spans) == 0 {
+		return nil
+	}
+	b, err := json.Marshal(otlpTracesData(spans))
+	if err != nil {
+		return err
+	}
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	_, err = e.w.Write(append(b, '\n'))
+	return err
+}
+
+func (e *otlpFileExporter) Shutdown(ctx context.Context) error {
+	return nil
+}
+
+// The types below mirror the OTLP JSON encoding of the trace protos: fields
+// are lowerCamelCase, IDs are hex, 64 bit integers are strings and enums are
+// numbers.
+
+type otlpTraces struct {
+	ResourceSpans []otlpResourceSpans `json:"resourceSpans"`
+}
+
+type otlpResourceSpans struct {
+	Resource   otlpResource     `json:"resource"`
+	ScopeSpans []otlpScopeSpans `json:"scopeSpans"`
+	SchemaURL  string           `json:"schemaUrl,omitempty"`
+}
+
+type otlpResource struct {
+	Attributes []otlpKeyValue `json:"attributes,omitempty"`
+}
+
+type otlpScopeSpans struct {
+	Scope     otlpScope  `json:"scope"`
+	Spans     []otlpSpan `json:"spans"`
+	SchemaURL string     `json:"schemaUrl,omitempty"`
+}
+
+type otlpScope struct {
+	Name    string `json:"name,omitempty"`
+	Version string `json:"version,omitempty"`
+}
+
+type otlpSpan struct {
+	TraceID                string         `json:"traceId"`
+	SpanID                 string         `json:"spanId"`
+	TraceState             string         `json:"traceState,omitempty"`
+	ParentSpanID           string         `json:"parentSpanId,omitempty"`
+	Name                   string         `json:"name"`
+	Kind                   int            `json:"kind"`
+	StartTimeUnixNano      string         `json:"startTimeUnixNano"`
+	EndTimeUnixNano        string         `json:"endTimeUnixNano"`
+	Attributes             []otlpKeyValue `json:"attributes,omitempty"`
+	DroppedAttributesCount int            `json:"droppedAttributesCount,omitempty"`
+	Events                 []otlpEvent    `json:"events,omitempty"`
+	DroppedEventsCount     int            `json:"droppedEventsCount,omitempty"`
+	Links                  []otlpLink     `json:"links,omitempty"`
+	DroppedLinksCount      int            `json:"droppedLinksCount,omitempty"`
+	Status                 otlpStatus     `json:"status"`
+}
+
+type otlpEvent struct {
+	TimeUnixNano string         `json:"timeUnixNano"`
+	Name         string         `json:"name"`
+	Attributes   []otlpKeyValue `json:"attributes,omitempty"`
+}
+
+type otlpLink struct {
+	TraceID    string         `json:"traceId"`
+	SpanID     string         `json:"spanId"`
+	TraceState string         `json:"traceState,omitempty"`
+	Attributes []otlpKeyValue `json:"attributes,omitempty"`
+}
+
+type otlpStatus struct {
+	Message string `json:"message,omitempty"`
+	Code    int    `json:"code,omitempty"`
+}
+
+type otlpKeyValue struct {
+	Key   string       `json:"key"`
+	Value otlpAnyValue `json:"value"`
+}
+
+type otlpAnyValue struct {
+	StringValue *string         `json:"stringValue,omitempty"`
+	BoolValue   *bool           `json:"boolValue,omitempty"`
+	IntValue    *string         `json:"intValue,omitempty"`
+	DoubleValue *float64        `json:"doubleValue,omitempty"`
+	ArrayValue  *otlpArrayValue `json:"arrayValue,omitempty"`
+}
+
+type otlpArrayValue struct {
+	Values []otlpAnyValue `json:"values"`
+}
+
+// OTLP status codes. They differ from the order of codes.Code.
+const (
+	otlpStatusUnset = 0
+	otlpStatusOk    = 1
+	otlpStatusError = 2
+)
+
+// otlpTracesData groups spans by resource and instrumentation scope, keeping
+// the order they were ended in.
+func otlpTracesData(spans []sdktrace.ReadOnlySpan) otlpTraces {
+	var data otlpTraces
+	resources := make(map[attribute.Distinct]int)
+	scopes := make(map[attribute.Distinct]map[instrumentation.Scope]int)
+	for _, span := range spans {
+		res := span.Resource()
+		if res == nil {
+			res = resource.Empty()
+		}
+		ri, ok := resources[res.Equivalent()]
+		if !ok {
+			ri = len(data.ResourceSpans)
+			resources[res.Equivalent()] = ri
+			scopes[res.Equivalent()] = make(map[instrumentation.Scope]int)
+			data.ResourceSpans = append(data.ResourceSpans, otlpResourceSpans{
+				Resource:  otlpResource{Attributes: otlpAttributes(res.Attributes())},
+				SchemaURL: res.SchemaURL(),
+			})
+		}
+		rs := &data.ResourceSpans[ri]
+
+		scope := span.InstrumentationScope()
+		si, ok := scopes[res.Equivalent()][scope]
+		if !ok {
+			si = len(rs.ScopeSpans)
+			scopes[res.Equivalent()][scope] = si
+			rs.ScopeSpans = append(rs.ScopeSpans, otlpScopeSpans{
+				Scope:     otlpScope{Name: scope.Name, Version: scope.Version},
+				SchemaURL: scope.SchemaURL,
+			})
+		}
+		rs.ScopeSpans[si].Spans = append(rs.ScopeSpans[si].Spans, otlpSpanOf(span))
+	}
+	return data
+}
+
+func otlpSpanOf(span sdktrace.ReadOnlySpan) otlpSpan {
+	sc := span.SpanContext()
+	s := otlpSpan{
+		TraceID:                sc.TraceID().String(),
+		SpanID:                 sc.SpanID().String(),
+		TraceState:             sc.TraceState().String(),
+		Name:                   span.Name(),
+		Kind:                   int(span.SpanKind()),
+		StartTimeUnixNano:      strconv.FormatInt(span.StartTime().UnixNano(), 10),
+		EndTimeUnixNano:        strconv.FormatInt(span.EndTime().UnixNano(), 10),
+		Attributes:             otlpAttributes(span.Attributes()),
+		DroppedAttributesCount: span.DroppedAttributes(),
+		DroppedEventsCount:     span.DroppedEvents(),
+		DroppedLinksCount:      span.DroppedLinks(),
+		Status:                 otlpStatusOf(span.Status()),
+	}
+	if parent := span.Parent(); parent.SpanID().IsValid() {
+		s.ParentSpanID = parent.SpanID().String()
+	}
+	for _, event := range span.Events() {
+		s.Events = append(s.Events, otlpEvent{
+			TimeUnixNano: strconv.FormatInt(event.Time.UnixNano(), 10),
+			Name:         event.Name,
+			Attributes:   otlpAttributes(event.Attributes),
+		})
+	}
+	for _, link := range span.Links() {
+		s.Links = append(s.Links, otlpLink{
+			TraceID:    link.SpanContext.TraceID().String(),
+			SpanID:     link.SpanContext.SpanID().String(),
+			TraceState: link.SpanContext.TraceState().String(),
+			Attributes: otlpAttributes(link.Attributes),
+		})
+	}
+	return s
+}
+
+func otlpStatusOf(status sdktrace.Status) otlpStatus {
+	switch status.Code {
+	case codes.Error:
+		return otlpStatus{Code: otlpStatusError, Message: status.Description}
+	case codes.Ok:
+		return otlpStatus{Code: otlpStatusOk}
+	default:
+		return otlpStatus{Code: otlpStatusUnset}
+	}
+}
+
+func otlpAttributes(attrs []attribute.KeyValue) []otlpKeyValue {
+	if len(attrs) == 0 {
+		return nil
+	}
+	kvs := make([]otlpKeyValue, len(attrs))
+	for i, attr := range attrs {
+		kvs[i] = otlpKeyValue{Key: string(attr.Key), Value: otlpValue(attr.Value)}
+	}
+	return kvs
+}
+
+func otlpValue(v attribute.Value) otlpAnyValue {
+	switch v.Type() {
+	case attribute.BOOL:
+		b := v.AsBool()
+		return otlpAnyValue{BoolValue: &b}
+	case attribute.INT64:
+		i := strconv.FormatInt(v.AsInt64(), 10)
+		return otlpAnyValue{IntValue: &i}
+	case attribute.FLOAT64:
+		f := v.AsFloat64()
+		return otlpAnyValue{DoubleValue: &f}
+	case attribute.BOOLSLICE:
+		return otlpArray(v.AsBoolSlice(), attribute.BoolValue)
+	case attribute.INT64SLICE:
+		return otlpArray(v.AsInt64Slice(), attribute.Int64Value)
+	case attribute.FLOAT64SLICE:
+		return otlpArray(v.AsFloat64Slice(), attribute.Float64Value)
+	case attribute.STRINGSLICE:
+		return otlpArray(v.AsStringSlice(), attribute.StringValue)
+	default:
+		s := v.Emit()
+		return otlpAnyValue{StringValue: &s}
+	}
+}
+
+func otlpArray[E any](values []E, value func(E) attribute.Value) otlpAnyValue {
+	array := &otlpArrayValue{Values: make([]otlpAnyValue, len(values))}
+	for i, v := range values {
+		array.Values[i] = otlpValue(value(v))
+	}
+	return otlpAnyValue{ArrayValue: array}
+}
@@ -0,0 +1,81 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"go.opentelemetry.io/otel/trace"
+	"strings"
+	"testing"
+)
+
+func TestOTLPFileExporter(t *testing.T) {
+	ctx := context.Background()
+	var buf bytes.Buffer
+	provider := sdktrace.NewTracerProvider(
+		sdktrace.WithSyncer(newOTLPFileExporter(&buf)),
+		sdktrace.WithResource(resource.NewSchemaless(semconv.ServiceName(serviceName))),
+	)
+	tracer := provider.Tracer("test")
+
+	ctx, parent := tracer.Start(ctx, "parent", trace.WithSpanKind(trace.SpanKindServer))
+	_, child := tracer.Start(ctx, "child", trace.WithAttributes(
+		attribute.Bool("cache.hit", true),
+		attribute.Int64("todo.ids", 3),
+		attribute.StringSlice("aws.dynamodb.table_names", []string{"TodoItems"}),
+	))
+	child.RecordError(errors.New("redis unavailable"))
+	child.SetStatus(codes.Error, "redis unavailable")
+	child.End()
+	parent.End()
+	require.NoError(t, provider.Shutdown(ctx))
+
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	require.Len(t, lines, 2, "every export should be one line")
+
+	var data otlpTraces
+	require.NoError(t, json.Unmarshal([]byte(lines[0]), &data))
+	require.Len(t, data.ResourceSpans, 1)
+	resourceSpans := data.ResourceSpans[0]
+	assert.Contains(t, resourceSpans.Resource.Attributes, otlpKeyValue{
+		Key:   "service.name",
+		Value: otlpAnyValue{StringValue: ptr(serviceName)},
+	})
+	require.Len(t, resourceSpans.ScopeSpans, 1)
+	assert.Equal(t, "test", resourceSpans.ScopeSpans[0].Scope.Name)
+	require.Len(t, resourceSpans.ScopeSpans[0].Spans, 1)
+
+	span := resourceSpans.ScopeSpans[0].Spans[0]
+	assert.Equal(t, "child", span.Name)
+	assert.Equal(t, child.SpanContext().TraceID().String(), span.TraceID)
+	assert.Equal(t, parent.SpanContext().SpanID().String(), span.ParentSpanID)
+	assert.Equal(t, otlpStatus{Code: otlpStatusError, Message: "redis unavailable"}, span.Status)
+	assert.Equal(t, []otlpKeyValue{
+		{Key: "cache.hit", Value: otlpAnyValue{BoolValue: ptr(true)}},
+		{Key: "todo.ids", Value: otlpAnyValue{IntValue: ptr("3")}},
+		{Key: "aws.dynamodb.table_names", Value: otlpAnyValue{ArrayValue: &otlpArrayValue{
+			Values: []otlpAnyValue{{StringValue: ptr("TodoItems")}},
+		}}},
+	}, span.Attributes)
+	require.Len(t, span.Events, 1)
+	assert.Equal(t, "exception", span.Events[0].Name)
+
+	var next otlpTraces
+	require.NoError(t, json.Unmarshal([]byte(lines[1]), &next))
+	span = next.ResourceSpans[0].ScopeSpans[0].Spans[0]
+	assert.Equal(t, "parent", span.Name)
+	assert.Empty(t, span.ParentSpanID)
+	assert.Equal(t, int(trace.SpanKindServer), span.Kind)
+}
+
+func ptr[T any](v T) *T {
+	return &v
+}
@@ -0,0 +1,256 @@
+package todo
+
+import (
+	"context"
+	"fmt"
+	"github.com/anmho/caching/cache"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/google/uuid"
+	"go.opentelemetry.io/otel/attribute"
+	"log/slog"
+	"time"
+)
+
+// dynamoBatchGetLimit is the most keys BatchGetItem accepts per call.
+const dynamoBatchGetLimit = 100
+
+// FindTodosByIDs returns the todos of userID with the given IDs, in the same
+// order, skipping any that do not exist. Todos are read from the cache in one
+// round trip, and the misses are loaded from DynamoDB with BatchGetItem and
+// written back to the cache in one round trip.
+func (s *Service) FindTodosByIDs(ctx context.Context, userID uuid.UUID, ids []uuid.UUID) (todos []*Todo, err error) {
+	ctx, end := s.startSpan(ctx, "FindTodosByIDs", userID, attribute.Int("todo.ids", len(ids)))
+	defer end(&err)
+
+	found := make(map[uuid.UUID]*Todo, len(ids))
+	missing := ids
+
+	if s.cacheStrategy == cache.WriteBack {
+		missing = make([]uuid.UUID, 0, len(ids))
+		for _, id := range ids {
+			// Writes that have not been flushed yet are newer than DynamoDB.
+			if todo, ok := s.writeBehind.Pending(todoKey(userID, id)); ok {
+				found[id] = todo
+				continue
+			}
+			missing = append(missing, id)
+		}
+	}
+
+	if s.cacheStrategy != cache.UnsetStrategy {
+		var err error
+		missing, err = s.readTodosFromCache(ctx, userID, missing, found)
+		if err != nil {
+			slog.Error("batch cache read",
+				slog.Any("error", err),
+				slog.Any("userID", userID),
+			)
+		}
+	}
+
+	if len(missing) > 0 {
+		leases := s.acquireTodoLeases(ctx, userID, missing)
+		start := time.Now()
+		loaded, err := s.readTodosFromDynamo(ctx, userID, missing)
+		if err != nil {
+			s.releaseTodoLeases(ctx, userID, leases, nil)
+			return nil, err
+		}
+		for _, todo := range loaded {
+			found[todo.ID] = todo
+		}
+		s.fillTodosInCache(ctx, userID, loaded, leases, time.Since(start))
+		s.releaseTodoLeases(ctx, userID, leases, found)
+	}
+
+	todos = make([]*Todo, 0, len(ids))
+	for _, id := range ids {
+		if todo, ok := found[id]; ok {
+			todos = append(todos, todo)
+		}
+	}
+	return todos, nil
+}
+
+// readTodosFromCache adds the cached todos among ids to found, and returns the
+// IDs that still have to be read from DynamoDB. Stale entries count as misses,
+// and todos cached as missing are left out of both.
+func (s *Service) readTodosFromCache(
+	ctx context.Context,
+	userID uuid.UUID,
+	ids []uuid.UUID,
+	found map[uuid.UUID]*Todo,
+) ([]uuid.UUID, error) {
+	keys := make([]string, len(ids))
+	for i, id := range ids {
+		keys[i] = todoKey(userID, id)
+	}
+	results, err := s.cache.ReadItems(ctx, keys...)
+	if err != nil {
+		return ids, err
+	}
+
+	missing := make([]uuid.UUID, 0, len(ids))
+	for _, id := range ids {
+		result := results[todoKey(userID, id)]
+		switch {
+		case result.CacheHit && result.NotFound:
+		case result.CacheHit && !result.Stale && result.Data.UserID == userID:
+			found[id] = result.Data
+		default:
+			missing = append(missing, id)
+		}
+	}
+	return missing, nil
+}
+
+// acquireTodoLeases requests leases to fill the todos ids of userID, keyed by
+// todo ID. It returns nil when the cache is not filled or has no leases.
+func (s *Service) acquireTodoLeases(ctx context.Context, userID uuid.UUID, ids []uuid.UUID) map[uuid.UUID]*cache.Lease {
+	switch s.cacheStrategy {
+	case cache.UnsetStrategy, cache.WriteAround:
+		return nil
+	}
+
+	keys := make([]string, len(ids))
+	for i, id := range ids {
+		keys[i] = todoKey(userID, id)
+	}
+	byKey := s.cache.AcquireLeases(ctx, keys, withUserLeaseTag(userID))
+	if byKey == nil {
+		return nil
+	}
+	leases := make(map[uuid.UUID]*cache.Lease, len(ids))
+	for _, id := range ids {
+		leases[id] = byKey[todoKey(userID, id)]
+	}
+	return leases
+}
+
+// releaseTodoLeases gives up, in one round trip, the leases of the todos that
+// were not loaded, and so not filled.
+func (s *Service) releaseTodoLeases(ctx context.Context, userID uuid.UUID, leases map[uuid.UUID]*cache.Lease, loaded map[uuid.UUID]*Todo) {
+	unused := make(map[string]*cache.Lease, len(leases))
+	for id, lease := range leases {
+		if _, ok := loaded[id]; !ok {
+			unused[todoKey(userID, id)] = lease
+		}
+	}
+	err := s.cache.ReleaseLeases(ctx, unused)
+	if err != nil {
+		slog.Error("release todo leases",
+			slog.Any("error", err),
+			slog.Any("userID", userID),
+		)
+	}
+}
+
+// fillTodosInCache writes todos loaded from DynamoDB to the cache in one round
+// trip. The WriteAround strategy only caches todos that miss often, so it is
+// not filled.
+//
+// With leases each todo is filled under the lease acquired before it was
+// loaded, and todos whose lease was not granted or was lost are skipped, so a
+// fill never overwrites a newer write.
+func (s *Service) fillTodosInCache(ctx context.Context, userID uuid.UUID, todos []*Todo, leases map[uuid.UUID]*cache.Lease, cost time.Duration) {
+	if len(todos) == 0 {
+		return
+	}
+	switch s.cacheStrategy {
+	case cache.UnsetStrategy, cache.WriteAround:
+		return
+	}
+
+	items := make(map[string]*Todo, len(todos))
+	for _, todo := range todos {
+		items[todoKey(userID, todo.ID)] = todo
+	}
+	var err error
+	if leases != nil {
+		byKey := make(map[string]*cache.Lease, len(leases))
+		for id, lease := range leases {
+			byKey[todoKey(userID, id)] = lease
+		}
+		err = s.cache.FillItems(ctx, items, byKey, cache.WithComputeCost(cost), withUserTag(userID))
+	} else {
+		err = s.cache.WriteItems(ctx, items, cache.WithComputeCost(cost), withUserTag(userID))
+	}
+	if err != nil {
+		slog.Error("batch cache fill",
+			slog.Any("error", err),
+			slog.Any("userID", userID),
+		)
+	}
+}
+
+// readTodosFromDynamo reads the todos of userID with the given IDs, leaving
+// out any that do not exist.
+func (s *Service) readTodosFromDynamo(ctx context.Context, userID uuid.UUID, ids []uuid.UUID) ([]*Todo, error) {
+	todos := make([]*Todo, 0, len(ids))
+	for start := 0; start < len(ids); start += dynamoBatchGetLimit {
+		end := min(start+dynamoBatchGetLimit, len(ids))
+		keys := make([]map[string]types.AttributeValue, 0, end-start)
+		for _, id := range ids[start:end] {
+			keys = append(keys, map[string]types.AttributeValue{
+				"UserID": &types.AttributeValueMemberS{Value: userID.String()},
+				"ID":     &types.AttributeValueMemberS{Value: id.String()},
+			})
+		}
+
+		items, err := s.batchGetTodos(ctx, keys)
+		if err != nil {
+			return nil, err
+		}
+		for _, item := range items {
+			todo, err := deserializeTodoDynamo(item)
+			if err != nil {
+				return nil, err
+			}
+			todos = append(todos, todo)
+		}
+	}
+	return todos, nil
+}
+
+func (s *Service) batchGetTodos(ctx context.Context, keys []map[string]types.AttributeValue) ([]map[string]types.AttributeValue, error) {
+	var items []map[string]types.AttributeValue
+	backoff := 50 * time.Millisecond
+	for attempt := 0; ; attempt++ {
+		dynamoCtx, span := startDynamoSpan(ctx, "BatchGetItem")
+		output, err := s.dynamoClient.BatchGetItem(dynamoCtx, &dynamodb.BatchGetItemInput{
+			RequestItems: map[string]types.KeysAndAttributes{
+				TodoItemsTableName: {
+					Keys:           keys,
+					ConsistentRead: aws.Bool(true),
+				},
+			},
+			ReturnConsumedCapacity: types.ReturnConsumedCapacityTotal,
+		})
+		endDynamoSpan(span, output, err)
+		if err != nil {
+			return nil, err
+		}
+		items = append(items, output.Responses[TodoItemsTableName]...)
+
+		keys = output.UnprocessedKeys[TodoItemsTableName].Keys
+		if len(keys) == 0 {
+			return items, nil
+		}
+		if attempt == maxUnprocessedRetries {
+			return nil, fmt.Errorf("batch get todos: %d keys unprocessed", len(keys))
+		}
+
+		slog.Warn("batch get todos unprocessed keys",
+			slog.Int("unprocessed", len(keys)),
+			slog.Int("attempt", attempt),
+		)
+		select {
+		case <-time.After(backoff):
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+		backoff *= 2
+	}
+}
@@ -0,0 +1,208 @@
+package todo
+
+import (
+	"context"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"maps"
+	"sync"
+)
+
+var _ DynamoDBClient = (*fakeDynamo)(nil)
+
+// fakeDynamo is an in-memory TodoItems table. It understands the expressions
+// the service sends rather than DynamoDB's expression language, and counts
+// the calls made to it by operation.
+type fakeDynamo struct {
+	mu    sync.Mutex
+	items map[string]map[string]types.AttributeValue
+	calls map[string]int
+	// block, when set, holds back the responses of every GetItem and
+	// BatchGetItem until it is closed. The items are read before waiting, as
+	// by a slow response.
+	block chan struct{}
+	// err, when set, fails every GetItem and BatchGetItem.
+	err error
+}
+
+func newFakeDynamo() *fakeDynamo {
+	return &fakeDynamo{
+		items: make(map[string]map[string]types.AttributeValue),
+		calls: make(map[string]int),
+	}
+}
+
+func fakeDynamoKey(key map[string]types.AttributeValue) string {
+	userID := key["UserID"].(*types.AttributeValueMemberS).Value
+	id := key["ID"].(*types.AttributeValueMemberS).Value
+	return userID + "/" + id
+}
+
+func (d *fakeDynamo) called(operation string) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.calls[operation]++
+}
+
+// Calls returns how often operation was called.
+func (d *fakeDynamo) Calls(operation string) int {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.calls[operation]
+}
+
+// Fail makes every later GetItem and BatchGetItem fail with err, or succeed
+// again if err is nil.
+func (d *fakeDynamo) Fail(err error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.err = err
+}
+
+func (d *fakeDynamo) wait(ctx context.Context) error {
+	d.mu.Lock()
+	block, err := d.block, d.err
+	d.mu.Unlock()
+	if err != nil {
+		return err
+	}
+	if block == nil {
+		return nil
+	}
+	select {
+	case <-block:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Put stores todo directly, bypassing the service.
+func (d *fakeDynamo) Put(todo *Todo) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	item := serializeTodoDynamo(todo)
+	d.items[fakeDynamoKey(item)] = item
+}
+
+// Has reports whether the table holds a row for the key.
+func (d *fakeDynamo) Has(key map[string]types.AttributeValue) bool {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	_, ok := d.items[fakeDynamoKey(key)]
+	return ok
+}
+
+func (d *fakeDynamo) GetItem(ctx context.Context, params *dynamodb.GetItemInput, optFns ...func(*dynamodb.Options)) (*dynamodb.GetItemOutput, error) {
+	d.called("GetItem")
+	d.mu.Lock()
+	item := maps.Clone(d.items[fakeDynamoKey(params.Key)])
+	d.mu.Unlock()
+	if err := d.wait(ctx); err != nil {
+		return nil, err
+	}
+	return &dynamodb.GetItemOutput{Item: item}, nil
+}
+
+func (d *fakeDynamo) PutItem(ctx context.Context, params *dynamodb.PutItemInput, optFns ...func(*dynamodb.Options)) (*dynamodb.PutItemOutput, error) {
+	d.called("PutItem")
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.items[fakeDynamoKey(params.Item)] = maps.Clone(params.Item)
+	return &dynamodb.PutItemOutput{}, nil
+}
+
+func (d *fakeDynamo) UpdateItem(ctx context.Context, params *dynamodb.UpdateItemInput, optFns ...func(*dynamodb.Options)) (*dynamodb.UpdateItemOutput, error) {
+	d.called("UpdateItem")
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	key := fakeDynamoKey(params.Key)
+	item, ok := d.items[key]
+	if !ok {
+		if aws.ToString(params.ConditionExpression) == "attribute_exists(ID)" {
+			return nil, &types.ConditionalCheckFailedException{Message: aws.String("The conditional request failed")}
+		}
+		item = maps.Clone(params.Key)
+		d.items[key] = item
+	}
+
+	values := params.ExpressionAttributeValues
+	item["Title"] = values[":title"]
+	item["Description"] = values[":description"]
+	item["UpdatedAt"] = values[":updatedAt"]
+	if completedAt, ok := values[":completedAt"]; ok {
+		item["CompletedAt"] = completedAt
+	} else {
+		delete(item, "CompletedAt")
+	}
+
+	output := &dynamodb.UpdateItemOutput{}
+	if params.ReturnValues == types.ReturnValueAllNew {
+		output.Attributes = maps.Clone(item)
+	}
+	return output, nil
+}
+
+func (d *fakeDynamo) DeleteItem(ctx context.Context, params *dynamodb.DeleteItemInput, optFns ...func(*dynamodb.Options)) (*dynamodb.DeleteItemOutput, error) {
+	d.called("DeleteItem")
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	key := fakeDynamoKey(params.Key)
+	if _, ok := d.items[key]; !ok && aws.ToString(params.ConditionExpression) == "attribute_exists(ID)" {
+		return nil, &types.ConditionalCheckFailedException{Message: aws.String("The conditional request failed")}
+	}
+	delete(d.items, key)
+	return &dynamodb.DeleteItemOutput{}, nil
+}
+
+func (d *fakeDynamo) Query(ctx context.Context, params *dynamodb.QueryInput, optFns ...func(*dynamodb.Options)) (*dynamodb.QueryOutput, error) {
+	d.called("Query")
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	userID := params.ExpressionAttributeValues[":userID"].(*types.AttributeValueMemberS).Value
+	output := &dynamodb.QueryOutput{}
+	for _, item := range d.items {
+		if item["UserID"].(*types.AttributeValueMemberS).Value == userID {
+			output.Items = append(output.Items, maps.Clone(item))
+		}
+	}
+	return output, nil
+}
+
+func (d *fakeDynamo) BatchGetItem(ctx context.Context, params *dynamodb.BatchGetItemInput, optFns ...func(*dynamodb.Options)) (*dynamodb.BatchGetItemOutput, error) {
+	d.called("BatchGetItem")
+	d.mu.Lock()
+	var items []map[string]types.AttributeValue
+	for _, key := range params.RequestItems[TodoItemsTableName].Keys {
+		if item, ok := d.items[fakeDynamoKey(key)]; ok {
+			items = append(items, maps.Clone(item))
+		}
+	}
+	d.mu.Unlock()
+	if err := d.wait(ctx); err != nil {
+		return nil, err
+	}
+	return &dynamodb.BatchGetItemOutput{
+		Responses: map[string][]map[string]types.AttributeValue{TodoItemsTableName: items},
+	}, nil
+}
+
+func (d *fakeDynamo) BatchWriteItem(ctx context.Context, params *dynamodb.BatchWriteItemInput, optFns ...func(*dynamodb.Options)) (*dynamodb.BatchWriteItemOutput, error) {
+	d.called("BatchWriteItem")
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	for _, request := range params.RequestItems[TodoItemsTableName] {
+		if request.PutRequest != nil {
+			d.items[fakeDynamoKey(request.PutRequest.Item)] = maps.Clone(request.PutRequest.Item)
+		}
+		if request.DeleteRequest != nil {
+			delete(d.items, fakeDynamoKey(request.DeleteRequest.Key))
+		}
+	}
+	return &dynamodb.BatchWriteItemOutput{}, nil
+}
@@ -0,0 +1,82 @@
+package todo
+
+import (
+	"context"
+	"errors"
+	"github.com/anmho/caching/cache"
+	"github.com/google/uuid"
+	"log/slog"
+)
+
+// WithListCache caches the IDs of each user's todos in lists, so that
+// ListUserTodos can hydrate them through the todo cache instead of querying
+// DynamoDB. lists should be built WithLeases, so that a list loaded before a
+// todo was created cannot be cached after the create invalidated it.
+func WithListCache(lists *cache.Cache[[]uuid.UUID]) func(s *Service) {
+	return func(s *Service) {
+		s.lists = lists
+	}
+}
+
+func userListKey(userID uuid.UUID) string {
+	return "todos:" + userID.String()
+}
+
+// listUserTodosCached reads the IDs of the todos of userID from the list
+// cache and hydrates them with FindTodosByIDs. On a miss the list is queried
+// from DynamoDB and cached.
+func (s *Service) listUserTodosCached(ctx context.Context, userID uuid.UUID) ([]*Todo, error) {
+	key := userListKey(userID)
+	result, err := s.lists.ReadItem(ctx, key, withUserLeaseTag(userID))
+	if err != nil {
+		slog.Error("todo list cache read",
+			slog.Any("error", err),
+			slog.Any("userID", userID),
+		)
+		result = cache.ReadCacheResult[[]uuid.UUID]{}
+	}
+	if result.CacheHit && !result.Stale && result.Data != nil {
+		return s.FindTodosByIDs(ctx, userID, *result.Data)
+	}
+
+	lease := result.Lease
+	if result.CacheHit {
+		// A stale hit is refreshed here, under a lease like a miss.
+		lease = s.lists.AcquireLease(ctx, key, withUserLeaseTag(userID))
+	}
+
+	todos, err := s.queryUserTodos(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	ids := make([]uuid.UUID, len(todos))
+	for i, todo := range todos {
+		ids[i] = todo.ID
+	}
+	err = s.lists.WriteItem(ctx, key, &ids, cache.WithLease(lease), withUserTag(userID))
+	if err != nil && !errors.Is(err, cache.LeaseLostError) {
+		slog.Error("todo list cache fill",
+			slog.Any("error", err),
+			slog.Any("userID", userID),
+		)
+	}
+	return todos, nil
+}
+
+// invalidateUserList drops the cached list of userID after its todos changed.
+// Like a failed write-through, a failed invalidation is logged rather than
+// failing a write DynamoDB already accepted; the list then expires with its
+// TTL.
+func (s *Service) invalidateUserList(ctx context.Context, userID uuid.UUID) {
+	if s.lists == nil {
+		return
+	}
+	err := s.lists.InvalidateKey(ctx, userListKey(userID))
+	if err != nil {
+		slog.Error("todo list cache invalidate",
+			slog.Any("error", err),
+			slog.Any("userID", userID),
+		)
+	}
+}
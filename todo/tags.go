@@ -0,0 +1,38 @@
+package todo
+
+import (
+	"context"
+	"github.com/anmho/caching/cache"
+	"github.com/google/uuid"
+)
+
+// userTag tags every cache entry holding data of a user.
+func userTag(userID uuid.UUID) string {
+	return "user:" + userID.String()
+}
+
+func withUserTag(userID uuid.UUID) cache.WriteOpt {
+	return cache.WithTags(userTag(userID))
+}
+
+// withUserLeaseTag is the read option for entries filled withUserTag.
+func withUserLeaseTag(userID uuid.UUID) cache.ReadOpt {
+	return cache.WithLeaseTags(userTag(userID))
+}
+
+// InvalidateUserCache drops every cached todo and list of userID, for when
+// their account changes or is deleted. The todo and list caches share their
+// tags when they share a backend.
+func (s *Service) InvalidateUserCache(ctx context.Context, userID uuid.UUID) (err error) {
+	ctx, end := s.startSpan(ctx, "InvalidateUserCache", userID)
+	defer end(&err)
+
+	err = s.cache.InvalidateTag(ctx, userTag(userID))
+	if err != nil {
+		return err
+	}
+	if s.lists != nil {
+		return s.lists.InvalidateTag(ctx, userTag(userID))
+	}
+	return nil
+}
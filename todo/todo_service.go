@@ -2,22 +2,53 @@ package todo
 
 import (
 	"context"
+	"errors"
+	"fmt"
 	"github.com/anmho/caching/async"
 	"github.com/anmho/caching/cache"
 	"github.com/aws/aws-sdk-go-v2/aws"
 	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
 	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
 	"github.com/google/uuid"
+	"go.opentelemetry.io/otel/attribute"
 	"log/slog"
 	"time"
 )
 
 const TodoItemsTableName = "TodoItems"
 
+// TodoNotFoundError wraps cache.NotFoundError so that a cache.Loader reading
+// todos gets missing todos cached as tombstones.
+var TodoNotFoundError = fmt.Errorf("todo %w", cache.NotFoundError)
+
+func NewTodoNotFoundError(id uuid.UUID) error {
+	return fmt.Errorf("%s: %w", id, TodoNotFoundError)
+}
+
+// DynamoDBClient is the part of *dynamodb.Client the service uses.
+type DynamoDBClient interface {
+	GetItem(ctx context.Context, params *dynamodb.GetItemInput, optFns ...func(*dynamodb.Options)) (*dynamodb.GetItemOutput, error)
+	PutItem(ctx context.Context, params *dynamodb.PutItemInput, optFns ...func(*dynamodb.Options)) (*dynamodb.PutItemOutput, error)
+	UpdateItem(ctx context.Context, params *dynamodb.UpdateItemInput, optFns ...func(*dynamodb.Options)) (*dynamodb.UpdateItemOutput, error)
+	DeleteItem(ctx context.Context, params *dynamodb.DeleteItemInput, optFns ...func(*dynamodb.Options)) (*dynamodb.DeleteItemOutput, error)
+	Query(ctx context.Context, params *dynamodb.QueryInput, optFns ...func(*dynamodb.Options)) (*dynamodb.QueryOutput, error)
+	BatchGetItem(ctx context.Context, params *dynamodb.BatchGetItemInput, optFns ...func(*dynamodb.Options)) (*dynamodb.BatchGetItemOutput, error)
+	BatchWriteItem(ctx context.Context, params *dynamodb.BatchWriteItemInput, optFns ...func(*dynamodb.Options)) (*dynamodb.BatchWriteItemOutput, error)
+}
+
 type Service struct {
-	dynamoClient  *dynamodb.Client
+	dynamoClient  DynamoDBClient
 	cache         *cache.Cache[Todo]
 	cacheStrategy cache.Strategy
+
+	writeAroundMissThreshold int64
+	writeAroundMissWindow    time.Duration
+
+	writeBackJournal cache.Journal
+	writeBackOpts    []cache.WriteBehindOpt
+	writeBehind      *cache.WriteBehind[Todo]
+
+	lists *cache.Cache[[]uuid.UUID]
 }
 
 func WithCacheStrategy(strategy cache.Strategy) func(s *Service) {
@@ -26,22 +57,43 @@ func WithCacheStrategy(strategy cache.Strategy) func(s *Service) {
 	}
 }
 
+// WithWriteAroundPopulation sets how many times a todo must miss within window
+// before the WriteAround strategy caches it.
+func WithWriteAroundPopulation(threshold int64, window time.Duration) func(s *Service) {
+	return func(s *Service) {
+		s.writeAroundMissThreshold = threshold
+		s.writeAroundMissWindow = window
+	}
+}
+
 type CachedTodoResult struct {
 	Todo  *Todo
 	Found bool
 }
 
 func MakeService(
-	dynamoClient *dynamodb.Client,
+	dynamoClient DynamoDBClient,
 	todoCache *cache.Cache[Todo],
 	opts ...func(o *Service)) *Service {
 	s := &Service{
-		dynamoClient: dynamoClient,
-		cache:        todoCache,
+		dynamoClient:             dynamoClient,
+		cache:                    todoCache,
+		writeAroundMissThreshold: 2,
+		writeAroundMissWindow:    10 * time.Minute,
 	}
 	for _, opt := range opts {
 		opt(s)
 	}
+	if s.cacheStrategy == cache.WriteBack {
+		// Without WithWriteBackJournal, Start and writes fail with
+		// cache.NoJournalError.
+		s.writeBehind = cache.NewWriteBehind(
+			todoCache,
+			s.writeBackJournal,
+			s.flushTodosToDynamo,
+			s.writeBackOpts...,
+		)
+	}
 	return s
 }
 
@@ -50,50 +102,179 @@ func (s *Service) CreateTodo(
 	userID uuid.UUID,
 	title string,
 	description string,
-) (*Todo, error) {
-	todo := New(userID, title, description)
+) (todo *Todo, err error) {
+	ctx, end := s.startSpan(ctx, "CreateTodo", userID)
+	defer end(&err)
+
+	todo = New(userID, title, description)
+
+	if s.cacheStrategy == cache.WriteBack {
+		err := s.writeBehind.Write(ctx, todoKey(userID, todo.ID), todo, withUserTag(userID))
+		if err != nil {
+			return nil, err
+		}
+		s.invalidateUserList(ctx, userID)
+		return todo, nil
+	}
 
 	dynamoItem := serializeTodoDynamo(todo)
-	result, err := s.dynamoClient.PutItem(ctx, &dynamodb.PutItemInput{
-		Item:      dynamoItem,
-		TableName: aws.String(TodoItemsTableName),
+	dynamoCtx, span := startDynamoSpan(ctx, "PutItem")
+	result, err := s.dynamoClient.PutItem(dynamoCtx, &dynamodb.PutItemInput{
+		Item:                   dynamoItem,
+		TableName:              aws.String(TodoItemsTableName),
+		ReturnConsumedCapacity: types.ReturnConsumedCapacityTotal,
 	})
+	endDynamoSpan(span, result, err)
 	if err != nil {
 		return nil, err
 	}
 
 	slog.Info("create todo result", slog.Any("result", result))
 
+	switch s.cacheStrategy {
+	case cache.WriteThrough:
+		s.writeThroughToCache(ctx, todo)
+	}
+	s.invalidateUserList(ctx, userID)
+
 	return todo, nil
 }
 
 func (s *Service) FindTodoByID(
 	ctx context.Context,
 	userID uuid.UUID,
-	id uuid.UUID) (*Todo, error) {
+	id uuid.UUID) (todo *Todo, err error) {
+	ctx, end := s.startSpan(ctx, "FindTodoByID", userID, attribute.String("todo.id", id.String()))
+	defer end(&err)
 
 	// check cache first
 	switch s.cacheStrategy {
-	case cache.CacheAside:
-		result, err := s.readTodoFromCache(ctx, id)
+	case cache.CacheAside, cache.WriteThrough:
+		// WriteThrough keeps cached todos current on every write, so reads are
+		// served from the cache like CacheAside.
+		return s.findTodoCacheAside(ctx, userID, id)
+	case cache.WriteAround:
+		return s.findTodoWriteAround(ctx, userID, id)
+	case cache.ReadThrough:
+		result, err := s.cache.Fetch(ctx, todoKey(userID, id), s.todoLoader(userID, id), withUserTag(userID))
 		if err != nil {
 			return nil, err
 		}
+		if result.NotFound {
+			return nil, NewTodoNotFoundError(id)
+		}
+		return result.Data, nil
+	case cache.WriteBack:
+		// Writes that have not been flushed yet are newer than DynamoDB.
+		if todo, ok := s.writeBehind.Pending(todoKey(userID, id)); ok {
+			return todo, nil
+		}
+		return s.findTodoCacheAside(ctx, userID, id)
+	default:
+		item, err := s.readTodoFromDynamo(ctx, id, userID)
+		if err != nil {
+			return nil, err
+		}
+		return item, nil
+	}
+}
+
+func (s *Service) findTodoCacheAside(
+	ctx context.Context,
+	userID uuid.UUID,
+	id uuid.UUID) (*Todo, error) {
+	result, err := s.readTodoFromCache(ctx, userID, id)
+	if err != nil {
+		// DynamoDB still has the todo, so a failing cache only costs a miss.
+		slog.Error("todo cache read",
+			slog.Any("error", err),
+			slog.Any("userID", userID),
+			slog.Any("todoID", id),
+		)
+		result = cache.ReadCacheResult[Todo]{}
+	}
+
+	// Cache hit, immediately return
+	if result.CacheHit {
+		if result.NotFound {
+			return nil, NewTodoNotFoundError(id)
+		}
+		if result.Stale {
+			// Serve the stale todo now and refresh it in the background.
+			refreshCtx := context.WithoutCancel(ctx)
+			async.HandleAsync(func() {
+				lease := s.cache.AcquireLease(refreshCtx, todoKey(userID, id), withUserLeaseTag(userID))
+				if lease != nil && !lease.Granted() {
+					// Another reader is already refreshing it.
+					return
+				}
+				_, err := s.loadTodoCacheAside(refreshCtx, userID, id, lease)
+				if err != nil {
+					slog.Error("stale todo refresh",
+						slog.Any("error", err),
+						slog.Any("userID", userID),
+						slog.Any("todoID", id),
+					)
+				}
+			})
+		}
+		return result.Data, nil
+	}
 
-		// Cache hit, immediately return
-		if result.CacheHit {
+	item, err := s.loadTodoCacheAside(ctx, userID, id, result.Lease)
+	if err != nil {
+		// DynamoDB is failing, fall back to the stale todo if there is one.
+		if result.Stale && !errors.Is(err, TodoNotFoundError) {
+			slog.Warn("serving stale todo",
+				slog.Any("error", err),
+				slog.Any("userID", userID),
+				slog.Any("todoID", id),
+			)
 			return result.Data, nil
 		}
+		return nil, err
+	}
+	return item, nil
+}
 
+// loadTodoCacheAside reads a todo from DynamoDB and fills the cache with it in
+// the background. The fill is made under lease, the lease handed out with the
+// miss, so that it is dropped if UpdateTodo invalidated the todo meanwhile.
+func (s *Service) loadTodoCacheAside(
+	ctx context.Context,
+	userID uuid.UUID,
+	id uuid.UUID,
+	lease *cache.Lease) (*Todo, error) {
+	// Concurrent misses for the same todo of the same user share one DynamoDB
+	// read and one cache write.
+	return s.cache.Coalesce(ctx, todoKey(userID, id), func(ctx context.Context) (*Todo, error) {
+		// Fire and forget cache writes. They outlive the request, so they must
+		// not be cancelled with it.
+		fillCtx := context.WithoutCancel(ctx)
+
+		start := time.Now()
 		item, err := s.readTodoFromDynamo(ctx, id, userID)
+		if errors.Is(err, TodoNotFoundError) {
+			async.HandleAsync(func() {
+				s.writeTodoTombstone(fillCtx, userID, id, lease)
+			})
+			return nil, err
+		}
 		if err != nil {
+			// Let the next reader that misses fill the todo.
+			s.releaseTodoLease(fillCtx, userID, id, lease)
 			return nil, err
 		}
+		computeCost := cache.WithComputeCost(time.Since(start))
 
-		// Fire and forget cache write
 		async.HandleAsync(func() {
 			// if this fails transiently, not a big deal
-			err := s.writeTodoToCache(ctx, item)
+			err := s.writeTodoToCache(fillCtx, item, computeCost, cache.WithLease(lease))
+			if errors.Is(err, cache.LeaseLostError) {
+				// The todo changed since it was read, leave it to the next
+				// reader.
+				return
+			}
 			if err != nil {
 				slog.Error("async cache write",
 					slog.Any("error", err),
@@ -105,16 +286,127 @@ func (s *Service) FindTodoByID(
 		})
 
 		return item, nil
-	default:
-		item, err := s.readTodoFromDynamo(ctx, id, userID)
-		if err != nil {
-			return nil, err
+	})
+}
+
+// findTodoWriteAround reads through the cache but only fills it once a todo has
+// missed often enough, so that todos written and never read again stay out of
+// the cache.
+func (s *Service) findTodoWriteAround(
+	ctx context.Context,
+	userID uuid.UUID,
+	id uuid.UUID) (*Todo, error) {
+	result, err := s.readTodoFromCache(ctx, userID, id)
+	if err != nil {
+		// DynamoDB still has the todo, so a failing cache only costs a miss.
+		slog.Error("todo cache read",
+			slog.Any("error", err),
+			slog.Any("userID", userID),
+			slog.Any("todoID", id),
+		)
+		result = cache.ReadCacheResult[Todo]{}
+	}
+	if result.CacheHit && result.NotFound {
+		return nil, NewTodoNotFoundError(id)
+	}
+	if result.CacheHit && !result.Stale {
+		return result.Data, nil
+	}
+
+	item, err := s.readTodoFromDynamo(ctx, id, userID)
+	if errors.Is(err, TodoNotFoundError) {
+		s.writeTodoTombstone(ctx, userID, id, result.Lease)
+		return nil, err
+	}
+	if err != nil {
+		s.releaseTodoLease(ctx, userID, id, result.Lease)
+		if result.Stale {
+			slog.Warn("serving stale todo",
+				slog.Any("error", err),
+				slog.Any("userID", userID),
+				slog.Any("todoID", id),
+			)
+			return result.Data, nil
 		}
+		return nil, err
+	}
+
+	misses, err := s.cache.CountMiss(ctx, todoKey(userID, id), s.writeAroundMissWindow)
+	if err != nil {
+		slog.Error("write around miss count",
+			slog.Any("error", err),
+			slog.Any("userID", userID),
+			slog.Any("todoID", id),
+		)
+		s.releaseTodoLease(ctx, userID, id, result.Lease)
+		return item, nil
+	}
+	if misses < s.writeAroundMissThreshold {
+		// Not filling, so let the miss that crosses the threshold have the
+		// lease.
+		s.releaseTodoLease(ctx, userID, id, result.Lease)
 		return item, nil
 	}
+
+	fillCtx := context.WithoutCancel(ctx)
+	async.HandleAsync(func() {
+		err := s.writeTodoToCache(fillCtx, item, cache.WithLease(result.Lease))
+		if errors.Is(err, cache.LeaseLostError) {
+			return
+		}
+		if err == nil {
+			err = s.cache.ResetMisses(fillCtx, todoKey(userID, id))
+		}
+		if err != nil {
+			slog.Error("write around cache fill",
+				slog.Any("error", err),
+				slog.Any("userID", userID),
+				slog.Any("todoID", id),
+			)
+		}
+	})
+
+	return item, nil
+}
+
+// releaseTodoLease gives up a lease on the todo id of userID that will not be
+// used to fill the cache.
+func (s *Service) releaseTodoLease(ctx context.Context, userID uuid.UUID, id uuid.UUID, lease *cache.Lease) {
+	err := s.cache.ReleaseLease(ctx, todoKey(userID, id), lease)
+	if err != nil {
+		slog.Error("release todo lease",
+			slog.Any("error", err),
+			slog.Any("userID", userID),
+			slog.Any("todoID", id),
+		)
+	}
 }
 
+// ListUserTodos returns every todo of userID. With WithListCache the IDs of
+// the todos are cached and the todos are read through the todo cache.
 func (s *Service) ListUserTodos(
+	ctx context.Context,
+	userID uuid.UUID) (todos []*Todo, err error) {
+	ctx, end := s.startSpan(ctx, "ListUserTodos", userID)
+	defer end(&err)
+
+	if s.lists != nil {
+		todos, err = s.listUserTodosCached(ctx, userID)
+	} else {
+		todos, err = s.queryUserTodos(ctx, userID)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if s.cacheStrategy == cache.WriteBack {
+		todos = s.mergePendingTodos(userID, todos)
+	}
+
+	return todos, nil
+}
+
+func (s *Service) queryUserTodos(
 	ctx context.Context,
 	userID uuid.UUID) ([]*Todo, error) {
 	// add pagination with pagination token?
@@ -129,7 +421,9 @@ func (s *Service) ListUserTodos(
 			},
 		},
 	}
-	output, err := s.dynamoClient.Query(ctx, input)
+	dynamoCtx, span := startDynamoSpan(ctx, "Query")
+	output, err := s.dynamoClient.Query(dynamoCtx, input)
+	endDynamoSpan(span, output, err)
 	if err != nil {
 		return nil, err
 	}
@@ -141,7 +435,6 @@ func (s *Service) ListUserTodos(
 		}
 		todos = append(todos, todo)
 	}
-
 	return todos, nil
 }
 
@@ -163,31 +456,113 @@ func (s *Service) UpdateTodo(
 	ctx context.Context,
 	userID uuid.UUID,
 	id uuid.UUID,
-	params *UpdateParams) error {
+	params *UpdateParams) (err error) {
+	ctx, end := s.startSpan(ctx, "UpdateTodo", userID, attribute.String("todo.id", id.String()))
+	defer end(&err)
 
 	switch s.cacheStrategy {
-	case cache.CacheAside:
-		err := s.writeTodoToDynamo(ctx, id, userID, params)
+	case cache.CacheAside, cache.ReadThrough, cache.WriteAround:
+		_, err := s.writeTodoToDynamo(ctx, userID, id, params, false)
+		if err != nil {
+			return err
+		}
+		// A failed invalidation leaves the old todo cached until it expires,
+		// but the update itself has been made.
+		err = s.cache.InvalidateKey(ctx, todoKey(userID, id))
+		if err != nil {
+			slog.Error("update todo cache invalidate",
+				slog.Any("error", err),
+				slog.Any("userID", userID),
+				slog.Any("todoID", id),
+			)
+		}
+	case cache.WriteThrough:
+		// DynamoDB is the source of truth, so it is written first. If it
+		// fails the cache is left untouched.
+		todo, err := s.writeTodoToDynamo(ctx, userID, id, params, true)
+		if err != nil {
+			return err
+		}
+		s.writeThroughToCache(ctx, todo)
+	case cache.WriteBack:
+		err := s.updateTodoWriteBack(ctx, userID, id, params)
 		if err != nil {
 			return err
 		}
-		err = s.cache.InvalidateKey(ctx, id.String())
 	default:
-		err := s.writeTodoToDynamo(ctx, id, userID, params)
+		_, err := s.writeTodoToDynamo(ctx, userID, id, params, false)
 		if err != nil {
 			return err
 		}
 	}
+	return nil
+}
+
+// DeleteTodo deletes a todo of userID, returning TodoNotFoundError if it does
+// not exist.
+func (s *Service) DeleteTodo(ctx context.Context, userID uuid.UUID, id uuid.UUID) (err error) {
+	ctx, end := s.startSpan(ctx, "DeleteTodo", userID, attribute.String("todo.id", id.String()))
+	defer end(&err)
+
+	if s.cacheStrategy == cache.WriteBack {
+		// A queued write of the todo would recreate it once flushed.
+		err := s.writeBehind.FlushKey(ctx, todoKey(userID, id))
+		if err != nil {
+			return err
+		}
+	}
+
+	dynamoCtx, span := startDynamoSpan(ctx, "DeleteItem")
+	output, err := s.dynamoClient.DeleteItem(dynamoCtx, &dynamodb.DeleteItemInput{
+		Key: map[string]types.AttributeValue{
+			"UserID": &types.AttributeValueMemberS{Value: userID.String()},
+			"ID":     &types.AttributeValueMemberS{Value: id.String()},
+		},
+		TableName:              aws.String(TodoItemsTableName),
+		ConditionExpression:    aws.String("attribute_exists(ID)"),
+		ReturnConsumedCapacity: types.ReturnConsumedCapacityTotal,
+	})
+	endDynamoSpan(span, output, err)
+	var conditionErr *types.ConditionalCheckFailedException
+	if errors.As(err, &conditionErr) {
+		return NewTodoNotFoundError(id)
+	}
+	if err != nil {
+		return err
+	}
+
+	if s.cacheStrategy != cache.UnsetStrategy {
+		err = s.cache.InvalidateKey(ctx, todoKey(userID, id))
+		if err != nil {
+			slog.Error("delete todo cache invalidate",
+				slog.Any("error", err),
+				slog.Any("userID", userID),
+				slog.Any("todoID", id),
+			)
+		}
+	}
+	s.invalidateUserList(ctx, userID)
 
 	return nil
 }
 
-func (s *Service) DeleteTodo(userID uuid.UUID, todoID uuid.UUID) {
+// todoKey is the cache key of the todo id of userID. Todos are looked up by
+// both, so the key holds both: a lookup with the wrong user then neither
+// shares a load with the owner's nor caches the todo as missing for them.
+func todoKey(userID uuid.UUID, id uuid.UUID) string {
+	return userID.String() + ":" + id.String()
+}
 
+func (s *Service) readTodoFromCache(ctx context.Context, userID uuid.UUID, id uuid.UUID) (cache.ReadCacheResult[Todo], error) {
+	return s.cache.ReadItem(ctx, todoKey(userID, id), withUserLeaseTag(userID))
 }
 
-func (s *Service) readTodoFromCache(ctx context.Context, id uuid.UUID) (cache.ReadCacheResult[Todo], error) {
-	return s.cache.ReadItem(ctx, id.String())
+// todoLoader loads the todo id of userID from DynamoDB for read-through
+// reads of its key.
+func (s *Service) todoLoader(userID uuid.UUID, id uuid.UUID) cache.Loader[Todo] {
+	return func(ctx context.Context, key string) (*Todo, error) {
+		return s.readTodoFromDynamo(ctx, id, userID)
+	}
 }
 
 func (s *Service) readTodoFromDynamo(ctx context.Context, id uuid.UUID, userID uuid.UUID) (*Todo, error) {
@@ -201,10 +576,15 @@ func (s *Service) readTodoFromDynamo(ctx context.Context, id uuid.UUID, userID u
 		ReturnConsumedCapacity: types.ReturnConsumedCapacityTotal,
 	}
 
-	result, err := s.dynamoClient.GetItem(ctx, params)
+	dynamoCtx, span := startDynamoSpan(ctx, "GetItem")
+	result, err := s.dynamoClient.GetItem(dynamoCtx, params)
+	endDynamoSpan(span, result, err)
 	if err != nil {
 		return nil, err
 	}
+	if len(result.Item) == 0 {
+		return nil, NewTodoNotFoundError(id)
+	}
 
 	todo, err := deserializeTodoDynamo(result.Item)
 	if err != nil {
@@ -213,15 +593,57 @@ func (s *Service) readTodoFromDynamo(ctx context.Context, id uuid.UUID, userID u
 	return todo, nil
 }
 
-func (s *Service) writeTodoToCache(ctx context.Context, todo *Todo) error {
-	return s.cache.WriteItem(ctx, todo.ID.String(), todo)
+func (s *Service) writeTodoToCache(ctx context.Context, todo *Todo, opts ...cache.WriteOpt) error {
+	opts = append(opts, withUserTag(todo.UserID))
+	return s.cache.WriteItem(ctx, todoKey(todo.UserID, todo.ID), todo, opts...)
+}
+
+// writeTodoTombstone caches that a todo does not exist, so that lookups of it
+// do not reach DynamoDB for a while. Failing to is not worth failing the read.
+func (s *Service) writeTodoTombstone(ctx context.Context, userID uuid.UUID, id uuid.UUID, lease *cache.Lease) {
+	err := s.cache.WriteMissing(ctx, todoKey(userID, id), cache.WithLease(lease), withUserTag(userID))
+	if err != nil && !errors.Is(err, cache.LeaseLostError) {
+		slog.Error("cache tombstone write",
+			slog.Any("error", err),
+			slog.Any("userID", userID),
+			slog.Any("todoID", id),
+		)
+	}
+}
+
+// writeThroughToCache writes a todo that has already been persisted to
+// DynamoDB into the cache. The write has succeeded once DynamoDB accepts it, so
+// a cache failure is not returned to the caller. Instead the key is invalidated
+// so that readers fall back to DynamoDB rather than seeing the previous value.
+func (s *Service) writeThroughToCache(ctx context.Context, todo *Todo) {
+	err := s.writeTodoToCache(ctx, todo)
+	if err == nil {
+		return
+	}
+	slog.Error("write through cache write",
+		slog.Any("error", err),
+		slog.Any("userID", todo.UserID),
+		slog.Any("todoID", todo.ID),
+	)
+
+	err = s.cache.InvalidateKey(ctx, todoKey(todo.UserID, todo.ID))
+	if err != nil {
+		slog.Error("write through cache invalidate",
+			slog.Any("error", err),
+			slog.Any("userID", todo.UserID),
+			slog.Any("todoID", todo.ID),
+		)
+	}
 }
 
-// this must be existing already. this is a put operation
+// writeTodoToDynamo updates an existing todo, returning TodoNotFoundError if
+// there is none. When returnNew is set the updated item is read back from
+// DynamoDB, otherwise the returned todo is nil.
 func (s *Service) writeTodoToDynamo(ctx context.Context,
 	userID uuid.UUID,
 	id uuid.UUID,
-	params *UpdateParams) error {
+	params *UpdateParams,
+	returnNew bool) (*Todo, error) {
 	expressionAttributeValues := map[string]types.AttributeValue{
 		":description": &types.AttributeValueMemberS{
 			Value: params.Description,
@@ -234,12 +656,25 @@ func (s *Service) writeTodoToDynamo(ctx context.Context,
 		},
 	}
 
+	updateExpression := `
+			SET 
+				Title = :title, 
+				Description = :description,
+				UpdatedAt = :updatedAt,
+				CompletedAt = :completedAt
+		`
 	if params.Completed {
 		expressionAttributeValues[":completedAt"] = &types.AttributeValueMemberS{
 			Value: formatDate(aws.Time(time.Now().UTC())),
 		}
 	} else {
-		expressionAttributeValues[":completedAt"] = nil
+		updateExpression = `
+			SET 
+				Title = :title, 
+				Description = :description,
+				UpdatedAt = :updatedAt
+			REMOVE CompletedAt
+		`
 	}
 
 	slog.Info("UpdateTodo", slog.Any("params", params), slog.Any(":completedAt", expressionAttributeValues[":completedAt"]))
@@ -252,22 +687,31 @@ func (s *Service) writeTodoToDynamo(ctx context.Context,
 				Value: id.String(),
 			},
 		},
-		TableName: aws.String(TodoItemsTableName),
-		UpdateExpression: aws.String(`
-			SET 
-				Title = :title, 
-				Description = :description,
-				UpdatedAt = :updatedAt,
-				CompletedAt = :completedAt
-		`),
+		TableName:                 aws.String(TodoItemsTableName),
+		UpdateExpression:          aws.String(updateExpression),
 		ExpressionAttributeValues: expressionAttributeValues,
-		ReturnConsumedCapacity:    types.ReturnConsumedCapacityTotal,
+		// Without it UpdateItem would create a todo with no CreatedAt.
+		ConditionExpression:    aws.String("attribute_exists(ID)"),
+		ReturnConsumedCapacity: types.ReturnConsumedCapacityTotal,
 		// does not return values to save rcu
 	}
+	if returnNew {
+		input.ReturnValues = types.ReturnValueAllNew
+	}
 
-	_, err := s.dynamoClient.UpdateItem(ctx, input)
+	dynamoCtx, span := startDynamoSpan(ctx, "UpdateItem")
+	output, err := s.dynamoClient.UpdateItem(dynamoCtx, input)
+	endDynamoSpan(span, output, err)
+	var conditionErr *types.ConditionalCheckFailedException
+	if errors.As(err, &conditionErr) {
+		return nil, NewTodoNotFoundError(id)
+	}
 	if err != nil {
-		return err
+		return nil, err
 	}
-	return nil
+	if !returnNew {
+		return nil, nil
+	}
+
+	return deserializeTodoDynamo(output.Attributes)
 }
@@ -0,0 +1,592 @@
+package todo
+
+import (
+	"context"
+	"errors"
+	"github.com/anmho/caching/cache"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"testing"
+	"time"
+)
+
+func newTestCache(opts ...cache.CacheOpt) *cache.Cache[Todo] {
+	return cache.New[Todo](cache.NewMemoryBackend(), opts...)
+}
+
+func newTestService(todoCache *cache.Cache[Todo], opts ...func(s *Service)) (*Service, *fakeDynamo) {
+	dynamo := newFakeDynamo()
+	return MakeService(dynamo, todoCache, opts...), dynamo
+}
+
+func dynamoKey(userID uuid.UUID, id uuid.UUID) map[string]types.AttributeValue {
+	return map[string]types.AttributeValue{
+		"UserID": &types.AttributeValueMemberS{Value: userID.String()},
+		"ID":     &types.AttributeValueMemberS{Value: id.String()},
+	}
+}
+
+func TestService_WriteThrough(t *testing.T) {
+	ctx := context.Background()
+	s, dynamo := newTestService(newTestCache(cache.WithLeases(time.Minute)), WithCacheStrategy(cache.WriteThrough))
+	userID := uuid.New()
+
+	created, err := s.CreateTodo(ctx, userID, "title", "description")
+	require.NoError(t, err)
+
+	found, err := s.FindTodoByID(ctx, userID, created.ID)
+	require.NoError(t, err)
+	assert.Equal(t, "title", found.Title)
+
+	err = s.UpdateTodo(ctx, userID, created.ID, &UpdateParams{Title: "updated", Description: "description"})
+	require.NoError(t, err)
+
+	found, err = s.FindTodoByID(ctx, userID, created.ID)
+	require.NoError(t, err)
+	assert.Equal(t, "updated", found.Title)
+	assert.Zero(t, dynamo.Calls("GetItem"), "reads should be served from the cache")
+}
+
+func TestService_UpdateMissingTodo(t *testing.T) {
+	ctx := context.Background()
+
+	tests := []struct {
+		desc     string
+		strategy cache.Strategy
+	}{
+		{desc: "no cache", strategy: cache.UnsetStrategy},
+		{desc: "cache aside", strategy: cache.CacheAside},
+		{desc: "write through", strategy: cache.WriteThrough},
+		{desc: "write around", strategy: cache.WriteAround},
+		{desc: "read through", strategy: cache.ReadThrough},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.desc, func(t *testing.T) {
+			s, dynamo := newTestService(newTestCache(cache.WithLeases(time.Minute)), WithCacheStrategy(tc.strategy))
+			userID, id := uuid.New(), uuid.New()
+
+			err := s.UpdateTodo(ctx, userID, id, &UpdateParams{Title: "title", Description: "description"})
+			assert.ErrorIs(t, err, TodoNotFoundError)
+			assert.False(t, dynamo.Has(dynamoKey(userID, id)), "the update should not create the todo")
+		})
+	}
+}
+
+func TestService_WriteAround(t *testing.T) {
+	ctx := context.Background()
+
+	tests := []struct {
+		desc      string
+		window    time.Duration
+		cacheOpts []cache.CacheOpt
+		run       func(t *testing.T, s *Service, dynamo *fakeDynamo, todoCache *cache.Cache[Todo])
+	}{
+		{
+			desc: "writes are not cached",
+			run: func(t *testing.T, s *Service, dynamo *fakeDynamo, todoCache *cache.Cache[Todo]) {
+				created, err := s.CreateTodo(ctx, uuid.New(), "title", "description")
+				require.NoError(t, err)
+
+				result, err := todoCache.ReadItem(ctx, todoKey(created.UserID, created.ID))
+				require.NoError(t, err)
+				assert.False(t, result.CacheHit)
+			},
+		},
+		{
+			desc: "a todo is cached once it misses the threshold number of times",
+			run: func(t *testing.T, s *Service, dynamo *fakeDynamo, todoCache *cache.Cache[Todo]) {
+				todo := New(uuid.New(), "title", "description")
+				dynamo.Put(todo)
+
+				for range 2 {
+					_, err := s.FindTodoByID(ctx, todo.UserID, todo.ID)
+					require.NoError(t, err)
+					result, err := todoCache.ReadItem(ctx, todoKey(todo.UserID, todo.ID))
+					require.NoError(t, err)
+					assert.False(t, result.CacheHit, "a todo below the threshold should not be cached")
+				}
+
+				_, err := s.FindTodoByID(ctx, todo.UserID, todo.ID)
+				require.NoError(t, err)
+				assert.Eventually(t, func() bool {
+					result, err := todoCache.ReadItem(ctx, todoKey(todo.UserID, todo.ID))
+					return err == nil && result.CacheHit
+				}, time.Second, time.Millisecond)
+
+				found, err := s.FindTodoByID(ctx, todo.UserID, todo.ID)
+				require.NoError(t, err)
+				assert.Equal(t, "title", found.Title)
+				assert.Equal(t, 3, dynamo.Calls("GetItem"))
+			},
+		},
+		{
+			desc:      "misses below the threshold do not hold the lease of the miss that fills",
+			cacheOpts: []cache.CacheOpt{cache.WithLeases(time.Minute)},
+			run: func(t *testing.T, s *Service, dynamo *fakeDynamo, todoCache *cache.Cache[Todo]) {
+				todo := New(uuid.New(), "title", "description")
+				dynamo.Put(todo)
+
+				for range 3 {
+					_, err := s.FindTodoByID(ctx, todo.UserID, todo.ID)
+					require.NoError(t, err)
+				}
+				assert.Eventually(t, func() bool {
+					result, err := todoCache.ReadItem(ctx, todoKey(todo.UserID, todo.ID))
+					return err == nil && result.CacheHit
+				}, time.Second, time.Millisecond)
+			},
+		},
+		{
+			desc:   "misses outside the window do not count",
+			window: 10 * time.Millisecond,
+			run: func(t *testing.T, s *Service, dynamo *fakeDynamo, todoCache *cache.Cache[Todo]) {
+				todo := New(uuid.New(), "title", "description")
+				dynamo.Put(todo)
+
+				for range 3 {
+					_, err := s.FindTodoByID(ctx, todo.UserID, todo.ID)
+					require.NoError(t, err)
+					time.Sleep(20 * time.Millisecond)
+				}
+				result, err := todoCache.ReadItem(ctx, todoKey(todo.UserID, todo.ID))
+				require.NoError(t, err)
+				assert.False(t, result.CacheHit)
+			},
+		},
+		{
+			desc: "an update invalidates the cached todo",
+			run: func(t *testing.T, s *Service, dynamo *fakeDynamo, todoCache *cache.Cache[Todo]) {
+				todo := New(uuid.New(), "title", "description")
+				dynamo.Put(todo)
+				for range 3 {
+					_, err := s.FindTodoByID(ctx, todo.UserID, todo.ID)
+					require.NoError(t, err)
+				}
+				assert.Eventually(t, func() bool {
+					result, err := todoCache.ReadItem(ctx, todoKey(todo.UserID, todo.ID))
+					return err == nil && result.CacheHit
+				}, time.Second, time.Millisecond)
+
+				err := s.UpdateTodo(ctx, todo.UserID, todo.ID, &UpdateParams{Title: "updated", Description: "description"})
+				require.NoError(t, err)
+
+				found, err := s.FindTodoByID(ctx, todo.UserID, todo.ID)
+				require.NoError(t, err)
+				assert.Equal(t, "updated", found.Title)
+			},
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.desc, func(t *testing.T) {
+			todoCache := newTestCache(tc.cacheOpts...)
+			window := tc.window
+			if window == 0 {
+				window = time.Minute
+			}
+			s, dynamo := newTestService(todoCache,
+				WithCacheStrategy(cache.WriteAround),
+				WithWriteAroundPopulation(3, window),
+			)
+			tc.run(t, s, dynamo, todoCache)
+		})
+	}
+}
+
+func TestService_ConcurrentFindsByOtherUser(t *testing.T) {
+	ctx := context.Background()
+
+	tests := []struct {
+		desc     string
+		strategy cache.Strategy
+	}{
+		{desc: "cache aside", strategy: cache.CacheAside},
+		{desc: "read through", strategy: cache.ReadThrough},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.desc, func(t *testing.T) {
+			s, dynamo := newTestService(newTestCache(cache.WithLeases(time.Minute)), WithCacheStrategy(tc.strategy))
+			todo := New(uuid.New(), "title", "description")
+			dynamo.Put(todo)
+			dynamo.block = make(chan struct{})
+
+			owner := make(chan error)
+			other := make(chan error)
+			go func() {
+				_, err := s.FindTodoByID(ctx, todo.UserID, todo.ID)
+				owner <- err
+			}()
+			go func() {
+				_, err := s.FindTodoByID(ctx, uuid.New(), todo.ID)
+				other <- err
+			}()
+
+			require.Eventually(t, func() bool {
+				return dynamo.Calls("GetItem") == 2
+			}, time.Second, time.Millisecond, "each user's lookup should load separately")
+			close(dynamo.block)
+
+			assert.NoError(t, <-owner)
+			assert.ErrorIs(t, <-other, TodoNotFoundError)
+		})
+	}
+}
+
+func TestService_MissingTodoOfOtherUser(t *testing.T) {
+	ctx := context.Background()
+
+	tests := []struct {
+		desc     string
+		strategy cache.Strategy
+	}{
+		{desc: "cache aside", strategy: cache.CacheAside},
+		{desc: "read through", strategy: cache.ReadThrough},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.desc, func(t *testing.T) {
+			todoCache := newTestCache(cache.WithLeases(time.Minute), cache.WithNegativeTTL(time.Minute))
+			s, dynamo := newTestService(todoCache, WithCacheStrategy(tc.strategy))
+			todo := New(uuid.New(), "title", "description")
+			dynamo.Put(todo)
+
+			_, err := s.FindTodoByID(ctx, uuid.New(), todo.ID)
+			require.ErrorIs(t, err, TodoNotFoundError)
+
+			found, err := s.FindTodoByID(ctx, todo.UserID, todo.ID)
+			require.NoError(t, err)
+			assert.Equal(t, todo.ID, found.ID)
+
+			todos, err := s.FindTodosByIDs(ctx, todo.UserID, []uuid.UUID{todo.ID})
+			require.NoError(t, err)
+			assert.Len(t, todos, 1)
+		})
+	}
+}
+
+func TestService_FindTodosByIDs(t *testing.T) {
+	ctx := context.Background()
+
+	tests := []struct {
+		desc string
+		run  func(t *testing.T, s *Service, dynamo *fakeDynamo, todoCache *cache.Cache[Todo])
+	}{
+		{
+			desc: "loaded todos are cached",
+			run: func(t *testing.T, s *Service, dynamo *fakeDynamo, todoCache *cache.Cache[Todo]) {
+				userID := uuid.New()
+				a, b := New(userID, "a", "description"), New(userID, "b", "description")
+				dynamo.Put(a)
+				dynamo.Put(b)
+
+				todos, err := s.FindTodosByIDs(ctx, userID, []uuid.UUID{a.ID, uuid.New(), b.ID})
+				require.NoError(t, err)
+				require.Len(t, todos, 2)
+				assert.Equal(t, "a", todos[0].Title)
+				assert.Equal(t, "b", todos[1].Title)
+
+				todos, err = s.FindTodosByIDs(ctx, userID, []uuid.UUID{a.ID, b.ID})
+				require.NoError(t, err)
+				assert.Len(t, todos, 2)
+				assert.Equal(t, 1, dynamo.Calls("BatchGetItem"))
+			},
+		},
+		{
+			desc: "an update during the load is not overwritten by the fill",
+			run: func(t *testing.T, s *Service, dynamo *fakeDynamo, todoCache *cache.Cache[Todo]) {
+				todo := New(uuid.New(), "title", "description")
+				dynamo.Put(todo)
+				dynamo.block = make(chan struct{})
+
+				found := make(chan error)
+				go func() {
+					_, err := s.FindTodosByIDs(ctx, todo.UserID, []uuid.UUID{todo.ID})
+					found <- err
+				}()
+				require.Eventually(t, func() bool {
+					return dynamo.Calls("BatchGetItem") == 1
+				}, time.Second, time.Millisecond)
+
+				err := s.UpdateTodo(ctx, todo.UserID, todo.ID, &UpdateParams{Title: "updated", Description: "description"})
+				require.NoError(t, err)
+				close(dynamo.block)
+				require.NoError(t, <-found)
+
+				updated, err := s.FindTodoByID(ctx, todo.UserID, todo.ID)
+				require.NoError(t, err)
+				assert.Equal(t, "updated", updated.Title)
+			},
+		},
+		{
+			desc: "a todo leased by another reader is not filled",
+			run: func(t *testing.T, s *Service, dynamo *fakeDynamo, todoCache *cache.Cache[Todo]) {
+				todo := New(uuid.New(), "title", "description")
+				dynamo.Put(todo)
+				held, err := todoCache.ReadItem(ctx, todoKey(todo.UserID, todo.ID))
+				require.NoError(t, err)
+				require.True(t, held.Lease.Granted())
+
+				_, err = s.FindTodosByIDs(ctx, todo.UserID, []uuid.UUID{todo.ID})
+				require.NoError(t, err)
+
+				result, err := todoCache.ReadItem(ctx, todoKey(todo.UserID, todo.ID))
+				require.NoError(t, err)
+				assert.False(t, result.CacheHit)
+			},
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.desc, func(t *testing.T) {
+			todoCache := newTestCache(cache.WithLeases(time.Minute))
+			s, dynamo := newTestService(todoCache, WithCacheStrategy(cache.CacheAside))
+			tc.run(t, s, dynamo, todoCache)
+		})
+	}
+}
+
+func TestService_DeleteTodo(t *testing.T) {
+	tests := []struct {
+		desc     string
+		strategy cache.Strategy
+	}{
+		{desc: "no cache", strategy: cache.UnsetStrategy},
+		{desc: "cache aside", strategy: cache.CacheAside},
+		{desc: "read through", strategy: cache.ReadThrough},
+		{desc: "write through", strategy: cache.WriteThrough},
+		{desc: "write around", strategy: cache.WriteAround},
+		{desc: "write back", strategy: cache.WriteBack},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.desc, func(t *testing.T) {
+			ctx := context.Background()
+			s, dynamo := newTestService(newTestCache(cache.WithLeases(time.Minute)),
+				WithCacheStrategy(tc.strategy),
+				WithWriteBackJournal(cache.NewMemoryJournal(), cache.WithFlushInterval(time.Hour)),
+			)
+			require.NoError(t, s.Start(ctx))
+			defer s.Drain(ctx)
+			userID := uuid.New()
+
+			created, err := s.CreateTodo(ctx, userID, "title", "description")
+			require.NoError(t, err)
+			_, err = s.FindTodoByID(ctx, userID, created.ID)
+			require.NoError(t, err)
+
+			require.NoError(t, s.DeleteTodo(ctx, userID, created.ID))
+			assert.False(t, dynamo.Has(dynamoKey(userID, created.ID)))
+			_, err = s.FindTodoByID(ctx, userID, created.ID)
+			assert.ErrorIs(t, err, TodoNotFoundError)
+			assert.ErrorIs(t, s.DeleteTodo(ctx, userID, created.ID), TodoNotFoundError)
+		})
+	}
+}
+
+func TestService_WriteBackDeleteFlushesOnlyTheTodo(t *testing.T) {
+	ctx := context.Background()
+	s, dynamo := newTestService(newTestCache(),
+		WithCacheStrategy(cache.WriteBack),
+		WithWriteBackJournal(cache.NewMemoryJournal(), cache.WithFlushInterval(time.Hour)),
+	)
+	require.NoError(t, s.Start(ctx))
+	userID := uuid.New()
+
+	deleted, err := s.CreateTodo(ctx, userID, "deleted", "description")
+	require.NoError(t, err)
+	kept, err := s.CreateTodo(ctx, userID, "kept", "description")
+	require.NoError(t, err)
+
+	require.NoError(t, s.DeleteTodo(ctx, userID, deleted.ID))
+	assert.False(t, dynamo.Has(dynamoKey(userID, deleted.ID)))
+	assert.False(t, dynamo.Has(dynamoKey(userID, kept.ID)), "other queued writes stay queued")
+
+	require.NoError(t, s.Drain(ctx))
+	assert.True(t, dynamo.Has(dynamoKey(userID, kept.ID)))
+	assert.False(t, dynamo.Has(dynamoKey(userID, deleted.ID)))
+}
+
+func TestService_CacheAsideListCache(t *testing.T) {
+	ctx := context.Background()
+	lists := cache.New[[]uuid.UUID](cache.NewMemoryBackend(), cache.WithLeases(time.Minute))
+	s, dynamo := newTestService(newTestCache(cache.WithLeases(time.Minute)),
+		WithCacheStrategy(cache.CacheAside),
+		WithListCache(lists),
+	)
+	userID := uuid.New()
+
+	first, err := s.CreateTodo(ctx, userID, "first", "description")
+	require.NoError(t, err)
+	todos, err := s.ListUserTodos(ctx, userID)
+	require.NoError(t, err)
+	require.Len(t, todos, 1)
+	_, err = s.ListUserTodos(ctx, userID)
+	require.NoError(t, err)
+	assert.Equal(t, 1, dynamo.Calls("Query"), "the list is cached")
+
+	second, err := s.CreateTodo(ctx, userID, "second", "description")
+	require.NoError(t, err)
+	todos, err = s.ListUserTodos(ctx, userID)
+	require.NoError(t, err)
+	assert.ElementsMatch(t, []uuid.UUID{first.ID, second.ID}, todoIDs(todos))
+
+	require.NoError(t, s.DeleteTodo(ctx, userID, first.ID))
+	todos, err = s.ListUserTodos(ctx, userID)
+	require.NoError(t, err)
+	assert.Equal(t, []uuid.UUID{second.ID}, todoIDs(todos))
+}
+
+func todoIDs(todos []*Todo) []uuid.UUID {
+	ids := make([]uuid.UUID, len(todos))
+	for i, todo := range todos {
+		ids[i] = todo.ID
+	}
+	return ids
+}
+
+func TestService_InvalidateUserCacheDuringLoad(t *testing.T) {
+	ctx := context.Background()
+	todoCache := newTestCache(cache.WithLeases(time.Minute))
+	s, dynamo := newTestService(todoCache, WithCacheStrategy(cache.CacheAside))
+	todo := New(uuid.New(), "title", "description")
+	dynamo.Put(todo)
+	dynamo.block = make(chan struct{})
+
+	found := make(chan error)
+	go func() {
+		_, err := s.FindTodoByID(ctx, todo.UserID, todo.ID)
+		found <- err
+	}()
+	require.Eventually(t, func() bool {
+		return dynamo.Calls("GetItem") == 1
+	}, time.Second, time.Millisecond)
+
+	require.NoError(t, s.InvalidateUserCache(ctx, todo.UserID))
+	close(dynamo.block)
+	require.NoError(t, <-found)
+
+	// The fill is done once its lease is gone, and must not have cached the
+	// todo loaded before the invalidation.
+	assert.Eventually(t, func() bool {
+		result, err := todoCache.ReadItem(ctx, todoKey(todo.UserID, todo.ID))
+		return err == nil && !result.CacheHit && result.Lease.Granted()
+	}, time.Second, time.Millisecond)
+}
+
+// failingBackend fails every Get and MGet, as a cache whose reads are down.
+type failingBackend struct {
+	cache.Backend
+	err error
+}
+
+func (b *failingBackend) Get(ctx context.Context, key string) ([]byte, error) {
+	return nil, b.err
+}
+
+func (b *failingBackend) MGet(ctx context.Context, keys ...string) ([][]byte, error) {
+	return nil, b.err
+}
+
+func TestService_FindTodoWithFailingCache(t *testing.T) {
+	tests := []struct {
+		desc     string
+		strategy cache.Strategy
+	}{
+		{desc: "cache aside", strategy: cache.CacheAside},
+		{desc: "read through", strategy: cache.ReadThrough},
+		{desc: "write through", strategy: cache.WriteThrough},
+		{desc: "write around", strategy: cache.WriteAround},
+		{desc: "write back", strategy: cache.WriteBack},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.desc, func(t *testing.T) {
+			ctx := context.Background()
+			backend := &failingBackend{Backend: cache.NewMemoryBackend(), err: errors.New("redis unavailable")}
+			s, dynamo := newTestService(cache.New[Todo](backend, cache.WithLeases(time.Minute)),
+				WithCacheStrategy(tc.strategy),
+				WithWriteBackJournal(cache.NewMemoryJournal()),
+			)
+			todo := New(uuid.New(), "title", "description")
+			dynamo.Put(todo)
+
+			found, err := s.FindTodoByID(ctx, todo.UserID, todo.ID)
+			require.NoError(t, err)
+			assert.Equal(t, todo.ID, found.ID)
+
+			_, err = s.FindTodoByID(ctx, todo.UserID, uuid.New())
+			assert.ErrorIs(t, err, TodoNotFoundError)
+		})
+	}
+}
+
+func TestService_WriteBackListCache(t *testing.T) {
+	ctx := context.Background()
+	lists := cache.New[[]uuid.UUID](cache.NewMemoryBackend(), cache.WithLeases(time.Minute))
+	s, dynamo := newTestService(newTestCache(cache.WithLeases(time.Minute)),
+		WithCacheStrategy(cache.WriteBack),
+		WithWriteBackJournal(cache.NewMemoryJournal(), cache.WithFlushInterval(time.Hour)),
+		WithListCache(lists),
+	)
+	require.NoError(t, s.Start(ctx))
+	userID := uuid.New()
+
+	created, err := s.CreateTodo(ctx, userID, "title", "description")
+	require.NoError(t, err)
+
+	// The list is cached while the todo is still queued.
+	todos, err := s.ListUserTodos(ctx, userID)
+	require.NoError(t, err)
+	require.Len(t, todos, 1)
+
+	require.NoError(t, s.Flush(ctx))
+	require.True(t, dynamo.Has(dynamoKey(userID, created.ID)))
+
+	todos, err = s.ListUserTodos(ctx, userID)
+	require.NoError(t, err)
+	require.Len(t, todos, 1)
+	assert.Equal(t, created.ID, todos[0].ID)
+	require.NoError(t, s.Drain(ctx))
+}
+
+func TestService_WriteBackWithoutJournal(t *testing.T) {
+	s, _ := newTestService(newTestCache(), WithCacheStrategy(cache.WriteBack))
+	assert.ErrorIs(t, s.Start(context.Background()), cache.NoJournalError)
+}
+
+func TestService_FailedLoadReleasesLease(t *testing.T) {
+	tests := []struct {
+		desc     string
+		strategy cache.Strategy
+	}{
+		{desc: "cache aside", strategy: cache.CacheAside},
+		{desc: "read through", strategy: cache.ReadThrough},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.desc, func(t *testing.T) {
+			ctx := context.Background()
+			todoCache := newTestCache(cache.WithLeases(time.Minute))
+			s, dynamo := newTestService(todoCache, WithCacheStrategy(tc.strategy))
+			todo := New(uuid.New(), "title", "description")
+			dynamo.Put(todo)
+
+			errUnavailable := errors.New("dynamodb unavailable")
+			dynamo.Fail(errUnavailable)
+			_, err := s.FindTodoByID(ctx, todo.UserID, todo.ID)
+			require.ErrorIs(t, err, errUnavailable)
+			dynamo.Fail(nil)
+
+			_, err = s.FindTodoByID(ctx, todo.UserID, todo.ID)
+			require.NoError(t, err)
+			assert.Eventually(t, func() bool {
+				result, err := todoCache.ReadItem(ctx, todoKey(todo.UserID, todo.ID))
+				return err == nil && result.CacheHit
+			}, time.Second, time.Millisecond)
+		})
+	}
+}
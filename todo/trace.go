@@ -0,0 +1,101 @@
+package todo
+
+import (
+	"context"
+	"errors"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/google/uuid"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// tracer returns the package's tracer, looked up on every use like cache's.
+func tracer() trace.Tracer {
+	return otel.Tracer("github.com/anmho/caching/todo")
+}
+
+// startSpan starts a span for a Service method and returns the function that
+// ends it with the method's error.
+func (s *Service) startSpan(ctx context.Context, method string, userID uuid.UUID, attrs ...attribute.KeyValue) (context.Context, func(err *error)) {
+	ctx, span := tracer().Start(ctx, "todo.Service."+method, trace.WithAttributes(append(attrs,
+		attribute.String("todo.cache_strategy", s.cacheStrategy.String()),
+		attribute.String("todo.user_id", userID.String()),
+	)...))
+	return ctx, func(err *error) {
+		endSpan(span, *err)
+	}
+}
+
+// endSpan ends span, marking it failed if err is set. A missing todo is an
+// answer rather than a failure, so it is only noted.
+func endSpan(span trace.Span, err error) {
+	if errors.Is(err, TodoNotFoundError) {
+		span.SetAttributes(attribute.Bool("todo.not_found", true))
+	} else if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
+
+// startDynamoSpan starts a client span for a DynamoDB call.
+func startDynamoSpan(ctx context.Context, operation string) (context.Context, trace.Span) {
+	return tracer().Start(ctx, "dynamodb."+operation,
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(
+			attribute.String("db.system", "dynamodb"),
+			attribute.String("db.operation", operation),
+			attribute.StringSlice("aws.dynamodb.table_names", []string{TodoItemsTableName}),
+		),
+	)
+}
+
+// endDynamoSpan records the capacity consumed by the call that returned
+// output, which may be nil, and ends its span.
+func endDynamoSpan(span trace.Span, output any, err error) {
+	var consumed []types.ConsumedCapacity
+	switch o := output.(type) {
+	case *dynamodb.GetItemOutput:
+		if o != nil && o.ConsumedCapacity != nil {
+			consumed = append(consumed, *o.ConsumedCapacity)
+		}
+	case *dynamodb.PutItemOutput:
+		if o != nil && o.ConsumedCapacity != nil {
+			consumed = append(consumed, *o.ConsumedCapacity)
+		}
+	case *dynamodb.UpdateItemOutput:
+		if o != nil && o.ConsumedCapacity != nil {
+			consumed = append(consumed, *o.ConsumedCapacity)
+		}
+	case *dynamodb.DeleteItemOutput:
+		if o != nil && o.ConsumedCapacity != nil {
+			consumed = append(consumed, *o.ConsumedCapacity)
+		}
+	case *dynamodb.QueryOutput:
+		if o != nil && o.ConsumedCapacity != nil {
+			consumed = append(consumed, *o.ConsumedCapacity)
+		}
+	case *dynamodb.BatchGetItemOutput:
+		if o != nil {
+			consumed = o.ConsumedCapacity
+		}
+	case *dynamodb.BatchWriteItemOutput:
+		if o != nil {
+			consumed = o.ConsumedCapacity
+		}
+	}
+
+	if len(consumed) > 0 {
+		var units float64
+		for _, c := range consumed {
+			if c.CapacityUnits != nil {
+				units += *c.CapacityUnits
+			}
+		}
+		span.SetAttributes(attribute.Float64("aws.dynamodb.consumed_capacity", units))
+	}
+	endSpan(span, err)
+}
@@ -0,0 +1,173 @@
+package todo
+
+import (
+	"context"
+	"fmt"
+	"github.com/anmho/caching/cache"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/google/uuid"
+	"log/slog"
+	"time"
+)
+
+const (
+	// dynamoBatchWriteLimit is the most items BatchWriteItem accepts per call.
+	dynamoBatchWriteLimit = 25
+	// maxUnprocessedRetries bounds how often unprocessed items are resent
+	// before the flush is failed and left for the next one.
+	maxUnprocessedRetries = 5
+)
+
+// WithWriteBackJournal sets the journal used to persist queued writes for the
+// WriteBack strategy, along with the flush thresholds. Every instance of the
+// service needs a journal of its own, see cache.NewRedisJournal.
+func WithWriteBackJournal(journal cache.Journal, opts ...cache.WriteBehindOpt) func(s *Service) {
+	return func(s *Service) {
+		s.writeBackJournal = journal
+		s.writeBackOpts = opts
+	}
+}
+
+// Start replays writes queued by a previous process and starts the background
+// flusher. It is a no-op unless the WriteBack strategy is selected, and fails
+// with cache.NoJournalError if no journal was set WithWriteBackJournal.
+func (s *Service) Start(ctx context.Context) error {
+	if s.writeBehind == nil {
+		return nil
+	}
+	return s.writeBehind.Start(ctx)
+}
+
+// Flush writes every queued todo to DynamoDB.
+func (s *Service) Flush(ctx context.Context) error {
+	if s.writeBehind == nil {
+		return nil
+	}
+	return s.writeBehind.Flush(ctx)
+}
+
+// Drain stops the background flusher and flushes every queued todo. It should
+// be called on shutdown.
+func (s *Service) Drain(ctx context.Context) error {
+	if s.writeBehind == nil {
+		return nil
+	}
+	return s.writeBehind.Drain(ctx)
+}
+
+func (s *Service) updateTodoWriteBack(
+	ctx context.Context,
+	userID uuid.UUID,
+	id uuid.UUID,
+	params *UpdateParams) error {
+	// The queue stores whole items, so the current todo is needed to apply
+	// the update to.
+	todo, err := s.FindTodoByID(ctx, userID, id)
+	if err != nil {
+		return err
+	}
+
+	now := time.Now().UTC()
+	updated := *todo
+	updated.Title = params.Title
+	updated.Description = params.Description
+	updated.UpdatedAt = &now
+	updated.CompletedAt = nil
+	if params.Completed {
+		updated.CompletedAt = &now
+	}
+
+	return s.writeBehind.Write(ctx, todoKey(userID, id), &updated, withUserTag(userID))
+}
+
+// mergePendingTodos overlays queued writes for userID on todos read from
+// DynamoDB so that a list reflects writes that have not been flushed yet.
+func (s *Service) mergePendingTodos(userID uuid.UUID, todos []*Todo) []*Todo {
+	pending := s.writeBehind.PendingItems()
+	if len(pending) == 0 {
+		return todos
+	}
+
+	merged := make([]*Todo, 0, len(todos))
+	for _, todo := range todos {
+		if queued, ok := pending[todoKey(todo.UserID, todo.ID)]; ok {
+			todo = queued
+		}
+		merged = append(merged, todo)
+	}
+
+	seen := make(map[uuid.UUID]bool, len(todos))
+	for _, todo := range todos {
+		seen[todo.ID] = true
+	}
+	for _, todo := range pending {
+		if todo.UserID == userID && !seen[todo.ID] {
+			merged = append(merged, todo)
+		}
+	}
+	return merged
+}
+
+// flushTodosToDynamo is the cache.FlushFunc for the WriteBack strategy. Once
+// the todos are in DynamoDB the lists of their users are invalidated, since a
+// list cached while a created todo was queued leaves it out.
+func (s *Service) flushTodosToDynamo(ctx context.Context, items map[string]*Todo) error {
+	requests := make([]types.WriteRequest, 0, len(items))
+	userIDs := make(map[uuid.UUID]bool)
+	for _, todo := range items {
+		requests = append(requests, types.WriteRequest{
+			PutRequest: &types.PutRequest{Item: serializeTodoDynamo(todo)},
+		})
+		userIDs[todo.UserID] = true
+	}
+
+	for start := 0; start < len(requests); start += dynamoBatchWriteLimit {
+		end := min(start+dynamoBatchWriteLimit, len(requests))
+		err := s.batchWriteTodos(ctx, requests[start:end])
+		if err != nil {
+			return err
+		}
+	}
+
+	for userID := range userIDs {
+		s.invalidateUserList(ctx, userID)
+	}
+	return nil
+}
+
+func (s *Service) batchWriteTodos(ctx context.Context, requests []types.WriteRequest) error {
+	backoff := 50 * time.Millisecond
+	for attempt := 0; ; attempt++ {
+		dynamoCtx, span := startDynamoSpan(ctx, "BatchWriteItem")
+		output, err := s.dynamoClient.BatchWriteItem(dynamoCtx, &dynamodb.BatchWriteItemInput{
+			RequestItems: map[string][]types.WriteRequest{
+				TodoItemsTableName: requests,
+			},
+			ReturnConsumedCapacity: types.ReturnConsumedCapacityTotal,
+		})
+		endDynamoSpan(span, output, err)
+		if err != nil {
+			return err
+		}
+
+		requests = output.UnprocessedItems[TodoItemsTableName]
+		if len(requests) == 0 {
+			return nil
+		}
+		if attempt == maxUnprocessedRetries {
+			return fmt.Errorf("batch write todos: %d items unprocessed", len(requests))
+		}
+
+		slog.Warn("batch write todos unprocessed items",
+			slog.Int("unprocessed", len(requests)),
+			slog.Int("attempt", attempt),
+		)
+		select {
+		case <-time.After(backoff):
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+		backoff *= 2
+	}
+}